@@ -0,0 +1,292 @@
+package pentview
+
+import (
+	"encoding/json"
+	"go-pentview/exports"
+	"go-pentview/openapi"
+	"go-pentview/services"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// withMessage is the response of operations that tell what they did along
+// with the record they did it to.
+func withMessage(key string, record any) openapi.Object {
+	return openapi.Object{"message": "", key: record}
+}
+
+var (
+	message = openapi.Object{"message": ""}
+	token   = openapi.Object{"access_token": ""}
+)
+
+// dataOf is the response of operations that read records.
+func dataOf(records any) openapi.Object {
+	return openapi.Object{"data": records}
+}
+
+var (
+	fromParam = openapi.Param{Name: "from", Description: "First day, YYYY-MM-DD"}
+	toParam   = openapi.Param{Name: "to", Description: "Last day, YYYY-MM-DD"}
+	tzParam   = openapi.Param{Name: "tz", Description: "IANA time zone, UTC by default"}
+	sizeParam = openapi.Param{Name: "size", Type: "integer", Description: "Side in pixels of a square variant",
+		Enum: []string{"64", "128", "256"}}
+)
+
+// exportParams are the query parameters of parseExportOptions.
+func exportParams() []openapi.Param {
+	formats := make([]string, 0, len(exports.Formats))
+	for format := range exports.Formats {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return []openapi.Param{
+		{Name: "format", Enum: formats, Description: "csv by default"},
+		fromParam,
+		toParam,
+		{Name: "user", Type: "integer"},
+		{Name: "role", Type: "integer"},
+		{Name: "team", Type: "integer"},
+		{Name: "columns", Description: "Comma separated keys, custom fields as field.<key>"},
+		{Name: "lang", Enum: []string{"es", "en"}, Description: "the language of the response by default"},
+		tzParam,
+	}
+}
+
+// exportTypes are the media types of the formats of exports.
+func exportTypes() []string {
+	var types []string
+	for _, format := range exports.Formats {
+		types = append(types, format.ContentType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// payrollLayouts are the payroll exporters loaded and their media types.
+func payrollLayouts() ([]string, []string) {
+	names := exports.ExporterNames()
+	var types []string
+	for _, name := range names {
+		exporter, _ := exports.GetExporter(name)
+		if !slices.Contains(types, exporter.ContentType()) {
+			types = append(types, exporter.ContentType())
+		}
+	}
+	sort.Strings(types)
+	return names, types
+}
+
+// apiDocument describes every route of Server.routes.
+func apiDocument() *openapi.Document {
+	const api = "/employee-service"
+	layouts, payrollTypes := payrollLayouts()
+
+	return openapi.New(openapi.Info{Title: "Pentview employee service", Version: "1.0.0"}, APIError{}, []openapi.Operation{
+		// Docs
+		{ID: "getOpenAPI", Method: "GET", Path: "/openapi.json", Tag: "docs", Public: true,
+			Summary: "This document", Response: openapi.Object{}},
+		{ID: "getDocs", Method: "GET", Path: "/docs", Tag: "docs", Public: true,
+			Summary: "Page to browse and try this document", Produces: []string{"text/html"}},
+
+		// Images
+		{ID: "getAvatar", Method: "GET", Path: "/upload/avatar/{id:[0-9]+}.{format:svg|png}", Tag: "images", Public: true,
+			Summary:  "Initials avatar of an active user without profile image, signed when images are private",
+			Query:    []openapi.Param{sizeParam, {Name: "expires", Type: "integer"}, {Name: "signature"}},
+			Produces: []string{"image/svg+xml", "image/png"}},
+		{ID: "getPFP", Method: "GET", Path: "/upload/{img}", Tag: "images", Public: true,
+			Summary:  "Uploaded profile image, signed when images are private",
+			Query:    []openapi.Param{sizeParam, {Name: "expires", Type: "integer"}, {Name: "signature"}},
+			Produces: []string{"image/jpeg", "image/png"}},
+
+		// Auth
+		{ID: "login", Method: "POST", Path: api + "/user/auth/login", Tag: "auth", Public: true,
+			Summary: "Log in", Body: services.Credentials{}, Response: token},
+		{ID: "activateUser", Method: "POST", Path: api + "/user/auth/activate", Tag: "auth", Public: true,
+			Summary: "Set the password of an invited user and log in", Body: services.Activation{}, Response: token},
+		{ID: "refreshToken", Method: "POST", Path: api + "/user/auth/refresh", Tag: "auth",
+			Summary: "New token for the user of a valid one, up to 12 hours after logging in", Response: token},
+
+		// Profile
+		{ID: "getProfile", Method: "GET", Path: api + "/user/profile", Tag: "profile",
+			Summary: "Profile of the user", Response: services.User{}},
+		{ID: "updateProfile", Method: "PUT", Path: api + "/user/update-profile", Tag: "profile",
+			Summary: "Update the profile of the user", Body: services.PutProfile{}, Response: withMessage("user", services.User{})},
+		{ID: "putProfileImage", Method: "PUT", Path: api + "/user/profile-image", Tag: "profile",
+			Summary: "Upload the profile image of the user", Form: []openapi.Param{{Name: "image", Type: "file", Required: true}},
+			Response: withMessage("user", services.User{})},
+		{ID: "deleteProfileImage", Method: "DELETE", Path: api + "/user/profile-image", Tag: "profile",
+			Summary: "Remove the profile image of the user", Response: withMessage("user", services.User{})},
+		{ID: "getBalance", Method: "GET", Path: api + "/user/balance", Tag: "balances",
+			Summary: "Leave balance of the user", Response: dataOf(services.Balance{})},
+		{ID: "getPersonalData", Method: "GET", Path: api + "/user/personal-data", Tag: "privacy",
+			Summary: "Archive with every record of the user", Produces: []string{"application/zip"}},
+		{ID: "getHourBalance", Method: "GET", Path: api + "/user/hour-balance", Tag: "contracts",
+			Summary: "Hours worked by the user against its contracts", Query: []openapi.Param{fromParam, toParam, tzParam},
+			Response: services.HourBalance{}},
+		{ID: "getNotifications", Method: "GET", Path: api + "/user/notifications", Tag: "notifications",
+			Summary: "Notifications of the user", Response: dataOf([]services.Notification{})},
+		{ID: "readNotification", Method: "PUT", Path: api + "/user/notifications/{id}", Tag: "notifications",
+			Summary: "Mark a notification as read", Response: message},
+
+		// Roles
+		{ID: "createRole", Method: "POST", Path: api + "/role", Tag: "roles",
+			Summary: "Create a role", Body: services.Role{}, Response: withMessage("role", services.Role{})},
+		{ID: "getRoles", Method: "GET", Path: api + "/role", Tag: "roles",
+			Summary: "Roles and how many users have them", Response: dataOf([]services.RoleUsage{})},
+		{ID: "updateRole", Method: "PUT", Path: api + "/role/{id}", Tag: "roles",
+			Summary: "Rename a role", Body: services.Role{}, Response: withMessage("role", services.Role{})},
+		{ID: "deleteRole", Method: "DELETE", Path: api + "/role/{id}", Tag: "roles",
+			Summary: "Delete a role", Response: message,
+			Query: []openapi.Param{{Name: "reassign", Type: "integer", Description: "Role to move the users of the deleted one to"}}},
+
+		// Users
+		{ID: "createUser", Method: "POST", Path: api + "/user", Tag: "users",
+			Summary: "Create a user, invited when it has no password", Response: message,
+			Form: []openapi.Param{
+				{Name: "json", Required: true, JSON: services.UserToCreate{}},
+				{Name: "image", Type: "file"},
+			}},
+		{ID: "getUsers", Method: "GET", Path: api + "/user/list", Tag: "users",
+			Summary: "Search users, by page or by cursor, and by custom field with field.<key>=<value>", Response: services.UserPage{},
+			Query: []openapi.Param{
+				{Name: "search"},
+				{Name: "status", Enum: []string{"active", "inactive", "all"}},
+				{Name: "role", Type: "integer"},
+				{Name: "sort"},
+				{Name: "order", Enum: []string{"asc", "desc"}},
+				{Name: "page", Type: "integer"},
+				{Name: "limit", Type: "integer"},
+				{Name: "cursor", Type: "integer"},
+			}},
+		{ID: "importUsers", Method: "POST", Path: api + "/user/import", Tag: "users",
+			Summary: "Import users from a CSV, sent as the body or as the file of a form", Response: services.ImportResult{},
+			Form:  []openapi.Param{{Name: "file", Type: "file", Required: true}},
+			Query: []openapi.Param{{Name: "dryRun", Type: "boolean"}, {Name: "invite", Type: "boolean"}}},
+		{ID: "getReports", Method: "GET", Path: api + "/user/reports", Tag: "users",
+			Summary: "Users reporting to the user", Response: dataOf([]services.User{})},
+		{ID: "updateUser", Method: "PUT", Path: api + "/user/{id}", Tag: "users",
+			Summary: "Update a user, keeping its password and employee code when empty", Body: services.User{}, Response: withMessage("user", services.User{})},
+		{ID: "deleteUser", Method: "DELETE", Path: api + "/user/{id}", Tag: "users",
+			Summary: "Deactivate a user", Response: message},
+		{ID: "reactivateUser", Method: "PUT", Path: api + "/user/{id}/reactivate", Tag: "users",
+			Summary: "Reactivate a user", Response: message},
+		{ID: "sendInvitation", Method: "POST", Path: api + "/user/{id}/invitation", Tag: "users",
+			Summary: "Send again the invitation of a user", Response: message},
+		{ID: "getProfileImageURL", Method: "GET", Path: api + "/user/{id}/profile-image", Tag: "users",
+			Summary: "Signed URL of the profile image of a user", Query: []openapi.Param{sizeParam},
+			Response: openapi.Object{"url": "", "expiresAt": ""}},
+		{ID: "getUserPersonalData", Method: "GET", Path: api + "/user/{id}/personal-data", Tag: "privacy",
+			Summary: "Archive with every record of a user", Produces: []string{"application/zip"}},
+		{ID: "anonymizeUser", Method: "PUT", Path: api + "/user/{id}/anonymize", Tag: "privacy",
+			Summary: "Erase the personal data of a user, keeping its records", Response: message},
+		{ID: "purgeUser", Method: "DELETE", Path: api + "/user/{id}/purge", Tag: "privacy",
+			Summary: "Delete a user and every record of it", Response: message},
+		{ID: "createContract", Method: "POST", Path: api + "/user/{id}/contract", Tag: "contracts",
+			Summary: "Add a contract to a user", Body: services.Contract{}, Response: withMessage("contract", services.Contract{})},
+		{ID: "getContracts", Method: "GET", Path: api + "/user/{id}/contract", Tag: "contracts",
+			Summary: "Contracts of a user", Response: dataOf([]services.Contract{})},
+		{ID: "getUserHourBalance", Method: "GET", Path: api + "/user/{id}/hour-balance", Tag: "contracts",
+			Summary: "Hours worked by a user against its contracts", Query: []openapi.Param{fromParam, toParam, tzParam},
+			Response: services.HourBalance{}},
+		{ID: "setUserFields", Method: "PUT", Path: api + "/user/{id}/fields", Tag: "fields",
+			Summary: "Set the custom fields of a user", Body: map[string]string{}, Response: withMessage("user", services.User{})},
+		{ID: "setHierarchy", Method: "PUT", Path: api + "/user/{id}/hierarchy", Tag: "hierarchy",
+			Summary: "Set the team and manager of a user", Body: services.Hierarchy{}, Response: withMessage("user", services.User{})},
+		{ID: "getUserBalance", Method: "GET", Path: api + "/user/{id}/balance", Tag: "balances",
+			Summary: "Leave balance of a user", Response: dataOf(services.Balance{})},
+		{ID: "adjustBalance", Method: "POST", Path: api + "/user/{id}/balance/adjustment", Tag: "balances",
+			Summary: "Adjust the leave balance of a user", Body: services.Adjustment{}, Response: withMessage("entry", services.LedgerEntry{})},
+
+		// Balances
+		{ID: "setEntitlement", Method: "POST", Path: api + "/entitlement", Tag: "balances",
+			Summary: "Set the yearly leave of a user or role", Body: services.Entitlement{},
+			Response: withMessage("entitlement", services.Entitlement{})},
+		{ID: "getEntitlements", Method: "GET", Path: api + "/entitlement", Tag: "balances",
+			Summary: "Yearly leave of users and roles", Response: dataOf([]services.Entitlement{})},
+		{ID: "deleteEntitlement", Method: "DELETE", Path: api + "/entitlement/{id}", Tag: "balances",
+			Summary: "Delete an entitlement", Response: message},
+
+		// Hour register
+		{ID: "createClocking", Method: "POST", Path: api + "/hour-register", Tag: "hour register",
+			Summary: "Clock in or out", Body: services.Clocking{}, Response: withMessage("clocking", services.Clocking{})},
+		{ID: "getClockings", Method: "GET", Path: api + "/hour-register", Tag: "hour register",
+			Summary: "Clockings of the user", Response: dataOf([]services.Clocking{})},
+		{ID: "getReportClockings", Method: "GET", Path: api + "/hour-register/reports", Tag: "hour register",
+			Summary: "Clockings of the users reporting to the user", Response: dataOf([]services.Clocking{}),
+			Query: []openapi.Param{{Name: "review", Type: "boolean", Description: "Only those to review"}}},
+		{ID: "getClockingsToReview", Method: "GET", Path: api + "/hour-register/review", Tag: "hour register",
+			Summary: "Clockings closed automatically, to review", Response: dataOf([]services.Clocking{})},
+		{ID: "reviewClocking", Method: "PUT", Path: api + "/hour-register/{id}/review", Tag: "hour register",
+			Summary: "Correct the time of a clocking under review", Body: services.ClockingReview{},
+			Response: withMessage("clocking", services.Clocking{})},
+
+		// Exports
+		{ID: "exportClockings", Method: "GET", Path: api + "/export/hour-register", Tag: "exports",
+			Summary: "Export clockings", Query: exportParams(), Produces: exportTypes()},
+		{ID: "exportTimesheets", Method: "GET", Path: api + "/export/timesheet", Tag: "exports",
+			Summary: "Export worked hours by day", Query: exportParams(), Produces: exportTypes()},
+		{ID: "exportPayroll", Method: "GET", Path: api + "/export/payroll", Tag: "exports",
+			Summary: "Export worked hours in the layout of a payroll provider", Produces: payrollTypes,
+			Query: append(exportParams(), openapi.Param{Name: "layout", Required: true, Enum: layouts})},
+
+		// Contracts
+		{ID: "deleteContract", Method: "DELETE", Path: api + "/contract/{id}", Tag: "contracts",
+			Summary: "Delete a contract", Response: message},
+
+		// Fields
+		{ID: "createField", Method: "POST", Path: api + "/field", Tag: "fields",
+			Summary: "Define a custom field of users", Body: services.Field{}, Response: withMessage("field", services.Field{})},
+		{ID: "getFields", Method: "GET", Path: api + "/field", Tag: "fields",
+			Summary: "Custom fields of users", Response: dataOf([]services.Field{})},
+		{ID: "deleteField", Method: "DELETE", Path: api + "/field/{id}", Tag: "fields",
+			Summary: "Delete a custom field", Response: message},
+
+		// Hierarchy
+		{ID: "createDepartment", Method: "POST", Path: api + "/department", Tag: "hierarchy",
+			Summary: "Create a department", Body: services.Department{}, Response: withMessage("department", services.Department{})},
+		{ID: "getDepartments", Method: "GET", Path: api + "/department", Tag: "hierarchy",
+			Summary: "Departments", Response: dataOf([]services.Department{})},
+		{ID: "deleteDepartment", Method: "DELETE", Path: api + "/department/{id}", Tag: "hierarchy",
+			Summary: "Delete a department without teams", Response: message},
+		{ID: "createTeam", Method: "POST", Path: api + "/team", Tag: "hierarchy",
+			Summary: "Create a team", Body: services.Team{}, Response: withMessage("team", services.Team{})},
+		{ID: "getTeams", Method: "GET", Path: api + "/team", Tag: "hierarchy",
+			Summary: "Teams", Response: dataOf([]services.Team{})},
+		{ID: "deleteTeam", Method: "DELETE", Path: api + "/team/{id}", Tag: "hierarchy",
+			Summary: "Delete a team without members", Response: message},
+
+		// Pay periods
+		{ID: "createPayPeriod", Method: "POST", Path: api + "/pay-period", Tag: "pay periods",
+			Summary: "Create a pay period", Body: services.PayPeriod{}, Response: withMessage("period", services.PayPeriod{})},
+		{ID: "getPayPeriods", Method: "GET", Path: api + "/pay-period", Tag: "pay periods",
+			Summary: "Pay periods", Response: dataOf([]services.PayPeriod{})},
+		{ID: "closePayPeriod", Method: "PUT", Path: api + "/pay-period/{id}/close", Tag: "pay periods",
+			Summary: "Close a pay period, freezing its clockings", Response: withMessage("period", services.PayPeriod{})},
+		{ID: "reopenPayPeriod", Method: "PUT", Path: api + "/pay-period/{id}/reopen", Tag: "pay periods",
+			Summary: "Reopen a pay period", Response: withMessage("period", services.PayPeriod{})},
+	})
+}
+
+// getOpenAPI serves the document, encoded once.
+func (s *Server) getOpenAPI(doc *openapi.Document) http.HandlerFunc {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+	}
+}
+
+func (s *Server) getDocs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(openapi.Docs)
+	}
+}
@@ -0,0 +1,44 @@
+package pentview
+
+import (
+	"go-pentview/openapi"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestAPIDocumentCoversRoutes fails unless the document describes every
+// registered route and nothing else, so it cannot fall behind Server.routes.
+func TestAPIDocumentCoversRoutes(t *testing.T) {
+	s := &Server{Router: mux.NewRouter()}
+	s.routes()
+	doc := apiDocument()
+
+	registered := map[string]bool{}
+	err := s.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			path, _ := openapi.Path(template)
+			registered[method+" "+path] = true
+			if !doc.Has(method, template) {
+				t.Errorf("%s %s is registered but not documented", method, template)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, operation := range doc.Operations() {
+		if !registered[operation] {
+			t.Errorf("%s is documented but not registered", operation)
+		}
+	}
+}
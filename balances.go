@@ -0,0 +1,219 @@
+package pentview
+
+import (
+	"encoding/json"
+	"go-pentview/services"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// accrueBalances credits the monthly vacation accrual on start and then every
+// hour, the repository makes sure each month is only credited once.
+func (s *Server) accrueBalances() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if err := s.repo.AccrueBalances(time.Now()); err != nil {
+			log.Printf("accrual failed: %s\n", err)
+		}
+		<-ticker.C
+	}
+}
+
+func (s *Server) getBalance(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, user_id := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		balance, err := repo.GetBalance(user_id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		data := struct {
+			Data services.Balance `json:"data"`
+		}{Data: *balance}
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) getUserBalance(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		// Retrieve id
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		balance, err := repo.GetBalance(intid)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		data := struct {
+			Data services.Balance `json:"data"`
+		}{Data: *balance}
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) adjustBalance(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		// Retrieve id
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Retrieve json
+		var adjustment services.Adjustment
+		if err := decodeJSON(w, r, &adjustment); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Create ledger entry
+		entry, err := repo.AdjustBalance(intid, admin_id, adjustment)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response entry
+		res := struct {
+			Message string               `json:"message"`
+			Entry   services.LedgerEntry `json:"entry"`
+		}{localize(w, "adjustment_created"), *entry}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (s *Server) setEntitlement(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		// Retrieve json
+		var entitlement services.Entitlement
+		if err := decodeJSON(w, r, &entitlement); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Create or replace entitlement
+		entitlementSet, err := repo.SetEntitlement(entitlement)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response entitlement
+		res := struct {
+			Message     string               `json:"message"`
+			Entitlement services.Entitlement `json:"entitlement"`
+		}{localize(w, "entitlement_set"), *entitlementSet}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) getEntitlements(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		entitlements, _ := repo.AllEntitlements()
+		if len(entitlements) == 0 {
+			entitlements = []services.Entitlement{}
+		}
+		data := struct {
+			Data []services.Entitlement `json:"data"`
+		}{Data: entitlements}
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) deleteEntitlement(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := repo.DeleteEntitlement(intid); err != nil {
+			writeMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		res := struct {
+			Message string `json:"message"`
+		}{localize(w, "entitlement_deleted")}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package blobs keeps uploaded files out of the instance running the server,
+// so several instances can share them.
+package blobs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("blob not found")
+	ErrInvalidName      = errors.New("invalid blob name")
+	ErrInvalidSignature = errors.New("invalid or expired signature")
+)
+
+// Object is a stored blob. Uploads are images of a few MB at most, so they are
+// handled whole in memory.
+type Object struct {
+	Data        []byte
+	ContentType string
+	ModTime     time.Time
+}
+
+// Store saves blobs under flat names.
+type Store interface {
+	Put(name string, data []byte, contentType string) error
+	// Get fails with ErrNotFound when there is no blob called name
+	Get(name string) (*Object, error)
+	Exists(name string) (bool, error)
+	// Delete does not fail when there is no blob called name
+	Delete(name string) error
+	// SignedURL returns a URL the blob can be downloaded from until expiry,
+	// even when images are private
+	SignedURL(name string, expiry time.Duration) (string, error)
+}
+
+// S3Config is where the S3 compatible bucket lives. Endpoint is like
+// https://s3.us-east-1.amazonaws.com or http://localhost:9000 for MinIO.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// New returns an S3 store when a bucket is configured, or a Local store of dir
+// otherwise. The S3 store falls back to dir for the blobs shipped with the
+// server, like the default profile image. secret signs the URLs of dir.
+func New(dir string, secret string, config S3Config) Store {
+	local := &Local{Dir: dir, Secret: secret}
+	if config.Bucket == "" {
+		return local
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	return &Fallback{Store: &S3{S3Config: config}, Default: local}
+}
+
+// Fallback reads the blobs missing from Store from Default. Writes and deletes
+// only reach Store, so the blobs of Default are never lost.
+type Fallback struct {
+	Store
+	Default Store
+}
+
+func (f *Fallback) Get(name string) (*Object, error) {
+	object, err := f.Store.Get(name)
+	if errors.Is(err, ErrNotFound) {
+		return f.Default.Get(name)
+	}
+	return object, err
+}
+
+func (f *Fallback) Exists(name string) (bool, error) {
+	exists, err := f.Store.Exists(name)
+	if err != nil || exists {
+		return exists, err
+	}
+	return f.Default.Exists(name)
+}
+
+func (f *Fallback) SignedURL(name string, expiry time.Duration) (string, error) {
+	exists, err := f.Store.Exists(name)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		if exists, err := f.Default.Exists(name); err == nil && exists {
+			return f.Default.SignedURL(name, expiry)
+		}
+	}
+	return f.Store.SignedURL(name, expiry)
+}
+
+// Sign returns the signature of the download of name until the unix time
+// expires, as carried by the URLs of Local.SignedURL.
+func Sign(secret string, name string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(name + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the expires and signature parameters of a signed URL of name.
+func Verify(secret string, name string, expires string, signature string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, name, unix))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package blobs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Local keeps blobs as files of Dir. It only suits a single instance, or
+// several sharing Dir over a network filesystem.
+type Local struct {
+	Dir    string
+	Secret string
+}
+
+func (l *Local) path(name string) (string, error) {
+	if name == "" || filepath.Base(name) != name || name[0] == '.' {
+		return "", ErrInvalidName
+	}
+	return filepath.Join(l.Dir, name), nil
+}
+
+// Put writes the blob aside and renames it, so a half written blob is never
+// served.
+func (l *Local) Put(name string, data []byte, contentType string) error {
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(l.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Get(name string) (*Object, error) {
+	path, err := l.path(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Data: data, ContentType: http.DetectContentType(data), ModTime: info.ModTime()}, nil
+}
+
+func (l *Local) Exists(name string) (bool, error) {
+	path, err := l.path(name)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *Local) Delete(name string) error {
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// SignedURL returns the path the server serves the blob from, relative like
+// the profile images of users, carrying a signature checked with Verify.
+func (l *Local) SignedURL(name string, expiry time.Duration) (string, error) {
+	if _, err := l.path(name); err != nil {
+		return "", err
+	}
+	expires := time.Now().Add(expiry).Unix()
+	return fmt.Sprintf("upload/%s?expires=%d&signature=%s", name, expires, Sign(l.Secret, name, expires)), nil
+}
@@ -0,0 +1,252 @@
+// Package client calls the employee service API from Go programs, like the
+// kiosk app or the payroll sync job, instead of hand-written HTTP code.
+//
+// A Client logs in once and then keeps its token fresh: it is refreshed when
+// about to expire and, once expired or past the session limit of the server,
+// the client logs in again.
+//
+//	c := client.New("http://localhost:8099")
+//	if err := c.Login(ctx, "admin@yopmail.com", "admin@2024"); err != nil {
+//		return err
+//	}
+//	clocking, err := c.ClockIn(ctx, time.Now())
+//	if errors.Is(err, client.ErrAlreadyClocked) {
+//		// ...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prefix is the path the API is served under.
+const prefix = "/employee-service"
+
+// RefreshBefore is how long before it expires a token is refreshed.
+const RefreshBefore = 5 * time.Minute
+
+// ErrNotLoggedIn is returned by the calls that need a token before Login or
+// SetToken.
+var ErrNotLoggedIn = errors.New("client: not logged in")
+
+// Client calls the API at BaseURL. It is safe for concurrent use.
+type Client struct {
+	BaseURL string
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// Language is sent as Accept-Language, for the messages of responses.
+	Language string
+
+	mu          sync.Mutex
+	token       string
+	expires     time.Time
+	credentials *credentials
+}
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// New returns a client of the API at baseURL, like http://localhost:8099.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+type tokenResponse struct {
+	Token string `json:"access_token"`
+}
+
+// Login logs in as username. The credentials are kept to log in again when
+// the token expires without having been refreshed, like after a long idle.
+func (c *Client) Login(ctx context.Context, username string, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.login(ctx, &credentials{Username: username, Password: password})
+}
+
+func (c *Client) login(ctx context.Context, creds *credentials) error {
+	req, err := c.newRequest(ctx, http.MethodPost, "/user/auth/login", nil, creds)
+	if err != nil {
+		return err
+	}
+	var res tokenResponse
+	if err := c.do(req, &res); err != nil {
+		return err
+	}
+	c.setToken(res.Token)
+	c.credentials = creds
+	return nil
+}
+
+// Refresh replaces the token by a new one.
+func (c *Client) Refresh(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == "" {
+		return ErrNotLoggedIn
+	}
+	return c.refresh(ctx)
+}
+
+func (c *Client) refresh(ctx context.Context) error {
+	req, err := c.newRequest(ctx, http.MethodPost, "/user/auth/refresh", nil, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	var res tokenResponse
+	if err := c.do(req, &res); err != nil {
+		return err
+	}
+	c.setToken(res.Token)
+	return nil
+}
+
+// SetToken makes the client use token, obtained elsewhere. Without
+// credentials the client cannot log in again once it expires.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setToken(token)
+	c.credentials = nil
+}
+
+// Token returns the token in use, "" before logging in.
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+func (c *Client) setToken(token string) {
+	c.token = token
+	c.expires = expiry(token)
+}
+
+// expiry reads the exp claim of a JWT. Tokens are verified by the server, the
+// client only needs to know when to refresh them.
+func expiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+// validToken returns a token that will not expire soon, refreshing the current
+// one or logging in again as needed.
+func (c *Client) validToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.token == "":
+		return "", ErrNotLoggedIn
+	case c.expires.IsZero() || time.Until(c.expires) > RefreshBefore:
+	case time.Now().Before(c.expires):
+		err := c.refresh(ctx)
+		if errors.Is(err, ErrUnauthorized) && c.credentials != nil {
+			err = c.login(ctx, c.credentials)
+		}
+		if err != nil {
+			return "", err
+		}
+	case c.credentials != nil:
+		if err := c.login(ctx, c.credentials); err != nil {
+			return "", err
+		}
+	}
+	return c.token, nil
+}
+
+// newRequest returns a request to the path of the API, with body encoded as
+// JSON unless it is nil.
+func (c *Client) newRequest(ctx context.Context, method string, path string, query url.Values, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+	target := c.BaseURL + prefix + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// do sends req and decodes the JSON response into out, unless it is nil, or
+// the error of the API into an *Error.
+func (c *Client) do(req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+	if c.Language != "" {
+		req.Header.Set("Accept-Language", c.Language)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return decodeError(res)
+	}
+	if out == nil {
+		_, err := io.Copy(io.Discard, res.Body)
+		return err
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// call sends an authorized request with body as JSON and decodes the response
+// into out.
+func (c *Client) call(ctx context.Context, method string, path string, query url.Values, body any, out any) error {
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	return c.send(req, out)
+}
+
+// send authorizes req and does it.
+func (c *Client) send(req *http.Request, out any) error {
+	token, err := c.validToken(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return c.do(req, out)
+}
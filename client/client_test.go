@@ -0,0 +1,543 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	pentview "go-pentview"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	adminEmail    = "admin@yopmail.com"
+	adminPassword = "admin@2024"
+)
+
+var server *httptest.Server
+
+// TestMain serves the routes of the API on a database of a temporary
+// directory, which is also the working directory for the images.
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	templates, err := filepath.Abs("../data/payroll")
+	if err != nil {
+		log.Fatal(err)
+	}
+	pfp, err := os.ReadFile("../data/img/nopfp.png")
+	if err != nil {
+		log.Fatal(err)
+	}
+	dir, err := os.MkdirTemp("", "pentview")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "data", "img"), 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data", "img", "nopfp.png"), pfp, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+
+	for key, value := range map[string]string{
+		"DBPATH":              filepath.Join(dir, "test.db3"),
+		"TOKEN":               "test-secret",
+		"PAYROLL_TEMPLATES":   templates,
+		"PAYROLL_DAILY_HOURS": "8",
+		"CLOCKOUT_CUTOFF":     "12h",
+		"USER_RETENTION_DAYS": "1825",
+		"APP_URL":             "http://localhost:4200",
+		"INVITATION_TTL":      "72h",
+		"PRIVATE_IMAGES":      "false",
+	} {
+		os.Setenv(key, value)
+	}
+	server = httptest.NewServer(pentview.NewServer())
+	defer server.Close()
+	return m.Run()
+}
+
+func login(t *testing.T, email string, password string) *Client {
+	t.Helper()
+	c := New(server.URL)
+	if err := c.Login(context.Background(), email, password); err != nil {
+		t.Fatalf("login as %s: %s", email, err)
+	}
+	return c
+}
+
+// createUser creates a user with a password, of the role roleID or the admin
+// one when 0, and returns it.
+func createUser(t *testing.T, admin *Client, email string, roleID int64) User {
+	t.Helper()
+	ctx := context.Background()
+	if roleID == 0 {
+		roleID = 1
+	}
+	user := NewUser{FirstName: "Test", LastName: "User", Email: email, Password: "password1", RoleID: roleID}
+	if err := admin.CreateUser(ctx, user, nil); err != nil {
+		t.Fatalf("create %s: %s", email, err)
+	}
+	page, err := admin.SearchUsers(ctx, UserQuery{Search: email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 1 {
+		t.Fatalf("search %s: got %d users, want 1", email, len(page.Data))
+	}
+	return page.Data[0]
+}
+
+func TestLogin(t *testing.T) {
+	ctx := context.Background()
+	c := New(server.URL)
+
+	if _, err := c.Profile(ctx); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("profile before login: got %v, want ErrNotLoggedIn", err)
+	}
+	err := c.Login(ctx, adminEmail, "wrong password")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("login with a wrong password: got %v, want ErrInvalidCredentials", err)
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 || apiErr.RequestID == "" {
+		t.Errorf("login with a wrong password: got %#v, want a 401 with a request id", err)
+	}
+
+	if err := c.Login(ctx, adminEmail, adminPassword); err != nil {
+		t.Fatal(err)
+	}
+	if c.Token() == "" {
+		t.Error("no token after login")
+	}
+
+	c.SetToken("not a token")
+	if _, err := c.Profile(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("profile with an invalid token: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	c := login(t, adminEmail, adminPassword)
+
+	if err := c.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Profile(ctx); err != nil {
+		t.Fatalf("profile with the refreshed token: %s", err)
+	}
+
+	// A token about to expire is refreshed before the request.
+	c.mu.Lock()
+	c.expires = time.Now().Add(time.Minute)
+	c.mu.Unlock()
+	if _, err := c.Profile(ctx); err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	expires := c.expires
+	c.mu.Unlock()
+	if time.Until(expires) < RefreshBefore {
+		t.Errorf("token not refreshed, it expires at %s", expires)
+	}
+
+	// An expired token is replaced by logging in again.
+	c.mu.Lock()
+	c.expires = time.Now().Add(-time.Minute)
+	c.mu.Unlock()
+	if _, err := c.Profile(ctx); err != nil {
+		t.Fatalf("profile after the token expired: %s", err)
+	}
+
+}
+
+// sessionToken signs a token of the admin for a session started at authTime.
+func sessionToken(t *testing.T, authTime time.Time) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":       1,
+		"auth_time": authTime.Unix(),
+		"iat":       time.Now().Add(-time.Minute).Unix(),
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRefreshSessionLimit(t *testing.T) {
+	ctx := context.Background()
+	c := New(server.URL)
+
+	loggedIn := time.Now().Add(-11 * time.Hour).Truncate(time.Second)
+	c.SetToken(sessionToken(t, loggedIn))
+	if err := c.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := jwt.NewParser().ParseUnverified(c.Token(), jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authTime := token.Claims.(jwt.MapClaims)["auth_time"]; authTime != float64(loggedIn.Unix()) {
+		t.Errorf("refreshed auth_time: got %v, want %d", authTime, loggedIn.Unix())
+	}
+
+	c.SetToken(sessionToken(t, time.Now().Add(-13*time.Hour)))
+	if err := c.Refresh(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("refresh past the session limit: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	ctx := context.Background()
+	admin := login(t, adminEmail, adminPassword)
+	createUser(t, admin, "profile@example.com", 0)
+	c := login(t, "profile@example.com", "password1")
+
+	user, err := c.Profile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "profile@example.com" || !user.Active || user.Role.ID != 1 {
+		t.Errorf("profile: got %+v", user)
+	}
+
+	english := "en"
+	updated, err := c.UpdateProfile(ctx, ProfileUpdate{FirstName: "Changed", LastName: user.LastName, Email: user.Email, Language: &english})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.FirstName != "Changed" || updated.Language != "en" {
+		t.Errorf("updated profile: got %+v", updated)
+	}
+	// An empty language follows Accept-Language again.
+	none := ""
+	updated, err = c.UpdateProfile(ctx, ProfileUpdate{FirstName: "Changed", LastName: user.LastName, Email: user.Email, Language: &none})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Language != "" {
+		t.Errorf("profile without language: got %q", updated.Language)
+	}
+
+	_, err = c.UpdateProfile(ctx, ProfileUpdate{FirstName: "Changed", Email: "not an email"})
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("update with an invalid email: got %v, want ErrValidation", err)
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "email" || apiErr.Fields[0].Rule != "email" {
+		t.Errorf("update with an invalid email: got fields %+v", apiErr.Fields)
+	}
+	if _, err := c.UpdateProfile(ctx, ProfileUpdate{FirstName: "Changed", Email: adminEmail}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("update to a taken email: got %v, want ErrDuplicate", err)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	ctx := context.Background()
+	admin := login(t, adminEmail, adminPassword)
+
+	role, err := admin.CreateRole(ctx, "auditor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed, err := admin.RenameRole(ctx, role.ID, "auditors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed.Name != "AUDITORS" {
+		t.Errorf("renamed role: got %+v", renamed)
+	}
+	other, err := admin.CreateRole(ctx, "reviewers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := createUser(t, admin, "auditor@example.com", role.ID)
+
+	roles, err := admin.Roles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, usage := range roles {
+		if usage.ID == role.ID {
+			found = true
+			if usage.Users != 1 || usage.ActiveUsers != 1 {
+				t.Errorf("usage of %s: got %+v, want 1 user", usage.Name, usage)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("role %d not listed", role.ID)
+	}
+
+	if err := admin.DeleteRole(ctx, role.ID, 0); !errors.Is(err, ErrRoleInUse) {
+		t.Fatalf("delete a role in use: got %v, want ErrRoleInUse", err)
+	}
+	if err := admin.DeleteRole(ctx, role.ID, other.ID); err != nil {
+		t.Fatal(err)
+	}
+	page, err := admin.SearchUsers(ctx, UserQuery{Search: user.Email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 1 || page.Data[0].Role.ID != other.ID {
+		t.Errorf("user of the deleted role: got %+v, want role %d", page.Data, other.ID)
+	}
+	if err := admin.DeleteRole(ctx, role.ID, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete a deleted role: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	admin := login(t, adminEmail, adminPassword)
+	user := createUser(t, admin, "users@example.com", 0)
+
+	err := admin.CreateUser(ctx, NewUser{FirstName: "Test", LastName: "User", Email: "users@example.com", Password: "password1", RoleID: 1}, nil)
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("create a user twice: got %v, want ErrDuplicate", err)
+	}
+	err = admin.CreateUser(ctx, NewUser{FirstName: "Test", Email: "invalid", RoleID: 1}, nil)
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("create a user with an invalid email: got %v, want ErrValidation", err)
+	}
+
+	user.FirstName = "Renamed"
+	user.EmployeeCode = "E-1"
+	updated, err := admin.UpdateUser(ctx, user.ID, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.FirstName != "Renamed" || updated.EmployeeCode != "E-1" {
+		t.Errorf("updated user: got %+v", updated)
+	}
+	user.EmployeeCode = ""
+	updated, err = admin.UpdateUser(ctx, user.ID, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.EmployeeCode != "E-1" {
+		t.Errorf("update without employee code: got %q, want it kept", updated.EmployeeCode)
+	}
+	user.EmployeeCode = "E-123456789"
+	if _, err := admin.UpdateUser(ctx, user.ID, user); !errors.Is(err, ErrValidation) {
+		t.Errorf("update with an 11 characters employee code: got %v, want ErrValidation", err)
+	}
+	user.EmployeeCode = ""
+	if _, err := admin.UpdateUser(ctx, 1<<40, user); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update a missing user: got %v, want ErrNotFound", err)
+	}
+
+	if err := admin.DeactivateUser(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(server.URL).Login(ctx, user.Email, "password1"); !errors.Is(err, ErrInactive) {
+		t.Errorf("login as a deactivated user: got %v, want ErrInactive", err)
+	}
+	page, err := admin.SearchUsers(ctx, UserQuery{Search: user.Email, Status: "inactive"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Data[0].Active {
+		t.Errorf("inactive users: got %+v", page)
+	}
+	if err := admin.ReactivateUser(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	login(t, user.Email, "password1")
+}
+
+func TestUserIterator(t *testing.T) {
+	ctx := context.Background()
+	admin := login(t, adminEmail, adminPassword)
+	var ids []int64
+	for i := 0; i < 7; i++ {
+		user := createUser(t, admin, fmt.Sprintf("iterated%d@example.com", i), 0)
+		ids = append(ids, user.ID)
+	}
+
+	for _, query := range []UserQuery{
+		// By cursor, with a last page not full and a last page full.
+		{Search: "iterated", Limit: 3},
+		{Search: "iterated", Limit: 7},
+		// By page, likewise.
+		{Search: "iterated", Sort: "email", Limit: 3},
+		{Search: "iterated", Sort: "email", Limit: 7},
+		{Search: "iterated", Sort: "email", Desc: true, Limit: 2},
+	} {
+		users, err := admin.AllUsers(ctx, query)
+		if err != nil {
+			t.Fatalf("%+v: %s", query, err)
+		}
+		if len(users) != len(ids) {
+			t.Errorf("%+v: got %d users, want %d", query, len(users), len(ids))
+			continue
+		}
+		seen := map[int64]bool{}
+		for i, user := range users {
+			if seen[user.ID] {
+				t.Errorf("%+v: user %d repeated", query, user.ID)
+			}
+			seen[user.ID] = true
+			if query.Sort == "" && user.ID != ids[i] {
+				t.Errorf("%+v: user %d is %d, want %d", query, i, user.ID, ids[i])
+			}
+			if query.Sort == "email" {
+				want := fmt.Sprintf("iterated%d@example.com", i)
+				if query.Desc {
+					want = fmt.Sprintf("iterated%d@example.com", len(ids)-1-i)
+				}
+				if user.Email != want {
+					t.Errorf("%+v: user %d is %s, want %s", query, i, user.Email, want)
+				}
+			}
+		}
+	}
+
+	// The iteration starts at the cursor given.
+	users, err := admin.AllUsers(ctx, UserQuery{Search: "iterated", Limit: 2, Cursor: ids[3]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 || users[0].ID != ids[4] {
+		t.Errorf("from cursor %d: got %+v", ids[3], users)
+	}
+
+	it := admin.Users(ctx, UserQuery{Sort: "unknown"})
+	if it.Next() {
+		t.Error("iterating with an unknown sort field returned a user")
+	}
+	if it.Err() == nil {
+		t.Error("iterating with an unknown sort field did not fail")
+	}
+}
+
+func TestClockings(t *testing.T) {
+	ctx := context.Background()
+	admin := login(t, adminEmail, adminPassword)
+	createUser(t, admin, "clockings@example.com", 0)
+	c := login(t, "clockings@example.com", "password1")
+
+	in := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	clocking, err := c.ClockIn(ctx, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clocking.Type != ClockIn || !clocking.Register.Equal(in) {
+		t.Errorf("clock in: got %+v", clocking)
+	}
+	if _, err := c.ClockIn(ctx, in.Add(time.Minute)); !errors.Is(err, ErrAlreadyClocked) {
+		t.Errorf("clock in twice: got %v, want ErrAlreadyClocked", err)
+	}
+	if _, err := c.Clock(ctx, "lunch", in); !errors.Is(err, ErrValidation) {
+		t.Errorf("clock an unknown type: got %v, want ErrValidation", err)
+	}
+	if _, err := c.ClockOut(ctx, in.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	clockings, err := c.Clockings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clockings) != 2 || clockings[0].Type != ClockIn || clockings[1].Type != ClockOut {
+		t.Errorf("clockings: got %+v", clockings)
+	}
+}
+
+func TestAvatar(t *testing.T) {
+	ctx := context.Background()
+	admin := login(t, adminEmail, adminPassword)
+	user := createUser(t, admin, "avatar@example.com", 0)
+	avatar := fmt.Sprintf("%s/upload/avatar/%d.svg", server.URL, user.ID)
+
+	res, err := http.Get(avatar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("avatar of an active user: got %s", res.Status)
+	}
+
+	if err := admin.DeactivateUser(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	res, err = http.Get(avatar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("avatar of an inactive user: got %s, want 404", res.Status)
+	}
+}
+
+func TestDeactivatedToken(t *testing.T) {
+	ctx := context.Background()
+	admin := login(t, adminEmail, adminPassword)
+	user := createUser(t, admin, "deactivated@example.com", 0)
+	c := login(t, "deactivated@example.com", "password1")
+
+	if err := admin.DeactivateUser(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Profile(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("profile with the token of a deactivated user: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	ctx := context.Background()
+	admin := login(t, adminEmail, adminPassword)
+	role, err := admin.CreateRole(ctx, "employees")
+	if err != nil {
+		t.Fatal(err)
+	}
+	createUser(t, admin, "employee@example.com", role.ID)
+	c := login(t, "employee@example.com", "password1")
+
+	if _, err := c.CreateRole(ctx, "owners"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("create a role as an employee: got %v, want ErrUnauthorized", err)
+	}
+	user := NewUser{FirstName: "Evil", Email: "evil@example.com", Password: "password1", RoleID: 1}
+	if err := c.CreateUser(ctx, user, nil); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("create an admin as an employee: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestDeactivateAsEmployee(t *testing.T) {
+	ctx := context.Background()
+	admin := login(t, adminEmail, adminPassword)
+	role, err := admin.CreateRole(ctx, "clerks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	createUser(t, admin, "clerk@example.com", role.ID)
+	other := createUser(t, admin, "other-clerk@example.com", role.ID)
+	c := login(t, "clerk@example.com", "password1")
+
+	if err := c.DeactivateUser(ctx, other.ID); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("deactivate as an employee: got %v, want ErrUnauthorized", err)
+	}
+	if err := c.DeactivateUser(ctx, 1); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("deactivate the admin as an employee: got %v, want ErrUnauthorized", err)
+	}
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Clocking types.
+const (
+	ClockIn  = "in"
+	ClockOut = "out"
+)
+
+// Clocking is a clock in or out. Review marks those closed automatically
+// after a forgotten clock out, until a manager reviews them.
+type Clocking struct {
+	ID       int64     `json:"_id"`
+	Type     string    `json:"type"`
+	Register time.Time `json:"register"`
+	UserID   int64     `json:"user,omitempty"`
+	Review   bool      `json:"review"`
+}
+
+type clockingResponse struct {
+	Message  string   `json:"message"`
+	Clocking Clocking `json:"clocking"`
+}
+
+type clockingsResponse struct {
+	Data []Clocking `json:"data"`
+}
+
+// Clock registers a clocking of type ClockIn or ClockOut at the time at for
+// the user logged in. Clocking twice in a row the same type fails with
+// ErrAlreadyClocked.
+func (c *Client) Clock(ctx context.Context, clockingType string, at time.Time) (*Clocking, error) {
+	body := struct {
+		Type     string    `json:"type"`
+		Register time.Time `json:"register"`
+	}{clockingType, at.Truncate(time.Second)}
+	var res clockingResponse
+	if err := c.call(ctx, http.MethodPost, "/hour-register", nil, body, &res); err != nil {
+		return nil, err
+	}
+	return &res.Clocking, nil
+}
+
+func (c *Client) ClockIn(ctx context.Context, at time.Time) (*Clocking, error) {
+	return c.Clock(ctx, ClockIn, at)
+}
+
+func (c *Client) ClockOut(ctx context.Context, at time.Time) (*Clocking, error) {
+	return c.Clock(ctx, ClockOut, at)
+}
+
+// Clockings returns the clockings of the user logged in.
+func (c *Client) Clockings(ctx context.Context) ([]Clocking, error) {
+	return c.clockings(ctx, "/hour-register", nil)
+}
+
+// ReportClockings returns the clockings of the users reporting to the user
+// logged in, only those to review with toReview.
+func (c *Client) ReportClockings(ctx context.Context, toReview bool) ([]Clocking, error) {
+	query := url.Values{}
+	if toReview {
+		query.Set("review", "true")
+	}
+	return c.clockings(ctx, "/hour-register/reports", query)
+}
+
+// ClockingsToReview returns every clocking closed automatically, for admins.
+func (c *Client) ClockingsToReview(ctx context.Context) ([]Clocking, error) {
+	return c.clockings(ctx, "/hour-register/review", nil)
+}
+
+func (c *Client) clockings(ctx context.Context, path string, query url.Values) ([]Clocking, error) {
+	var res clockingsResponse
+	if err := c.call(ctx, http.MethodGet, path, query, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// ReviewClocking corrects the time of a clocking under review.
+func (c *Client) ReviewClocking(ctx context.Context, id int64, at time.Time) (*Clocking, error) {
+	body := struct {
+		Register time.Time `json:"register"`
+	}{at.Truncate(time.Second)}
+	var res clockingResponse
+	if err := c.call(ctx, http.MethodPut, fmt.Sprintf("/hour-register/%d/review", id), nil, body, &res); err != nil {
+		return nil, err
+	}
+	return &res.Clocking, nil
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Error is an error responded by the API. Code is stable, Message is meant
+// for people and translated to the Language of the client.
+type Error struct {
+	StatusCode int          `json:"-"`
+	Code       string       `json:"code"`
+	Message    string       `json:"message"`
+	Fields     []FieldError `json:"fields,omitempty"`
+	RequestID  string       `json:"requestId,omitempty"`
+}
+
+// FieldError tells which field of the request body broke which rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	message := fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+	for _, field := range e.Fields {
+		message += fmt.Sprintf("; %s %s", field.Field, field.Message)
+	}
+	return message
+}
+
+// Is makes errors.Is match errors by their code, so they can be compared to
+// the errors of this package.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Errors of the API, to compare with errors.Is.
+var (
+	ErrUnauthorized       = &Error{Code: "unauthorized"}
+	ErrInvalidCredentials = &Error{Code: "invalid_credentials"}
+	ErrNotActivated       = &Error{Code: "not_activated"}
+	ErrInactive           = &Error{Code: "inactive"}
+	ErrInvalidToken       = &Error{Code: "invalid_token"}
+	ErrNotFound           = &Error{Code: "not_found"}
+	ErrDuplicate          = &Error{Code: "duplicate"}
+	ErrValidation         = &Error{Code: "validation_failed"}
+	ErrInvalidJSON        = &Error{Code: "invalid_json"}
+	ErrBadRequest         = &Error{Code: "bad_request"}
+	ErrLastAdmin          = &Error{Code: "last_admin"}
+	ErrRoleInUse          = &Error{Code: "role_in_use"}
+	ErrAlreadyClocked     = &Error{Code: "already_clocked"}
+	ErrPeriodClosed       = &Error{Code: "period_closed"}
+	ErrInternal           = &Error{Code: "internal"}
+)
+
+// decodeError reads the error of res. Responses that are not an error of the
+// API, like those of a proxy, get the code of their status.
+func decodeError(res *http.Response) error {
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	apiErr := &Error{}
+	if json.Unmarshal(body, apiErr) != nil || apiErr.Code == "" {
+		apiErr = &Error{
+			Code:    strings.ToLower(strings.ReplaceAll(http.StatusText(res.StatusCode), " ", "_")),
+			Message: strings.TrimSpace(string(body)),
+		}
+	}
+	apiErr.StatusCode = res.StatusCode
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = res.Header.Get("X-Request-Id")
+	}
+	return apiErr
+}
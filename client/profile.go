@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// User is a user of the API. Password is only sent, never received.
+type User struct {
+	ID            int64             `json:"_id"`
+	FirstName     string            `json:"firstName"`
+	LastName      string            `json:"lastName"`
+	Email         string            `json:"email"`
+	Password      string            `json:"password,omitempty"`
+	ProfileImage  string            `json:"profileImage"`
+	CreatedAt     string            `json:"createdAt"`
+	EmployeeCode  string            `json:"employeeCode,omitempty"`
+	Active        bool              `json:"active"`
+	DeactivatedAt string            `json:"deactivatedAt,omitempty"`
+	TeamID        int64             `json:"team"`
+	ManagerID     int64             `json:"manager"`
+	Language      string            `json:"language"`
+	Fields        map[string]string `json:"fields"`
+	Role          Role              `json:"role"`
+}
+
+// ProfileUpdate are the fields users change of their own profile.
+type ProfileUpdate struct {
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Email     string `json:"email"`
+	// Language is kept when nil, and "" follows Accept-Language.
+	Language *string `json:"language,omitempty"`
+}
+
+type userResponse struct {
+	Message string `json:"message"`
+	User    User   `json:"user"`
+}
+
+// Profile returns the user logged in.
+func (c *Client) Profile(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.call(ctx, http.MethodGet, "/user/profile", nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateProfile changes the profile of the user logged in.
+func (c *Client) UpdateProfile(ctx context.Context, update ProfileUpdate) (*User, error) {
+	var res userResponse
+	if err := c.call(ctx, http.MethodPut, "/user/update-profile", nil, update, &res); err != nil {
+		return nil, err
+	}
+	return &res.User, nil
+}
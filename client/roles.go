@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type Role struct {
+	ID        int64  `json:"_id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// RoleUsage is a role with how many users, and of them how many active, have
+// it assigned.
+type RoleUsage struct {
+	Role
+	Users       int `json:"users"`
+	ActiveUsers int `json:"activeUsers"`
+}
+
+type roleResponse struct {
+	Message string `json:"message"`
+	Role    Role   `json:"role"`
+}
+
+// Roles returns every role.
+func (c *Client) Roles(ctx context.Context) ([]RoleUsage, error) {
+	var res struct {
+		Data []RoleUsage `json:"data"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/role", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+func (c *Client) CreateRole(ctx context.Context, name string) (*Role, error) {
+	var res roleResponse
+	if err := c.call(ctx, http.MethodPost, "/role", nil, Role{Name: name}, &res); err != nil {
+		return nil, err
+	}
+	return &res.Role, nil
+}
+
+func (c *Client) RenameRole(ctx context.Context, id int64, name string) (*Role, error) {
+	var res roleResponse
+	if err := c.call(ctx, http.MethodPut, fmt.Sprintf("/role/%d", id), nil, Role{Name: name}, &res); err != nil {
+		return nil, err
+	}
+	return &res.Role, nil
+}
+
+// DeleteRole deletes a role. A role assigned to users fails with
+// ErrRoleInUse unless reassign is the role to move them to.
+func (c *Client) DeleteRole(ctx context.Context, id int64, reassign int64) error {
+	query := url.Values{}
+	if reassign != 0 {
+		query.Set("reassign", strconv.FormatInt(reassign, 10))
+	}
+	return c.call(ctx, http.MethodDelete, fmt.Sprintf("/role/%d", id), query, nil, nil)
+}
@@ -0,0 +1,248 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// NewUser is a user to create. Without password the user is invited by mail
+// to choose one.
+type NewUser struct {
+	FirstName    string
+	LastName     string
+	Email        string
+	Password     string
+	EmployeeCode string
+	RoleID       int64
+	// Fields are the values of the custom fields by key, which must include
+	// the required ones.
+	Fields map[string]string
+}
+
+// UserQuery filters, sorts and pages the users of Users and SearchUsers.
+type UserQuery struct {
+	// Search matches users with every term in their name, last name or email.
+	Search string
+	// Status is active, inactive or all, active by default.
+	Status string
+	RoleID int64
+	// Fields match the values of custom fields by key.
+	Fields map[string]string
+	// Sort is the field to sort by, _id by default, and Desc reverses it.
+	Sort string
+	Desc bool
+	// Page counts from 1, Limit is at most 100 and Cursor is the NextCursor
+	// of the previous page, only when sorting by _id.
+	Page   int
+	Limit  int
+	Cursor int64
+}
+
+func (q UserQuery) values() url.Values {
+	values := url.Values{}
+	set := func(key string, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("search", q.Search)
+	set("status", q.Status)
+	set("sort", q.Sort)
+	if q.Desc {
+		values.Set("order", "desc")
+	}
+	for key, value := range q.Fields {
+		values.Set("field."+key, value)
+	}
+	for key, value := range map[string]int64{"role": q.RoleID, "page": int64(q.Page), "limit": int64(q.Limit), "cursor": q.Cursor} {
+		if value != 0 {
+			values.Set(key, strconv.FormatInt(value, 10))
+		}
+	}
+	return values
+}
+
+// UserPage is a page of users. Total counts every user of the query.
+type UserPage struct {
+	Data       []User `json:"data"`
+	Total      int    `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	NextCursor int64  `json:"nextCursor,omitempty"`
+}
+
+// SearchUsers returns a page of the users matching query.
+func (c *Client) SearchUsers(ctx context.Context, query UserQuery) (*UserPage, error) {
+	if query.Limit == 0 {
+		query.Limit = 20
+	}
+	var page UserPage
+	if err := c.call(ctx, http.MethodGet, "/user/list", query.values(), nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// UserIterator walks the users matching a query, fetching their pages as
+// needed. Pages are taken by cursor when sorting by _id, which does not skip
+// or repeat users created meanwhile.
+type UserIterator struct {
+	client *Client
+	ctx    context.Context
+	query  UserQuery
+	page   []User
+	index  int
+	done   bool
+	err    error
+}
+
+// Users returns an iterator over every user matching query, from its Page or
+// Cursor on.
+//
+//	it := c.Users(ctx, client.UserQuery{Status: "all"})
+//	for it.Next() {
+//		user := it.User()
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+func (c *Client) Users(ctx context.Context, query UserQuery) *UserIterator {
+	if query.Limit == 0 {
+		query.Limit = 100
+	}
+	return &UserIterator{client: c, ctx: ctx, query: query, index: -1}
+}
+
+// Next moves to the next user, and tells whether there is one.
+func (it *UserIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	it.index++
+	for it.index >= len(it.page) {
+		if it.done {
+			return false
+		}
+		page, err := it.client.SearchUsers(it.ctx, it.query)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.index = page.Data, 0
+		if it.query.Sort == "" || it.query.Sort == "_id" {
+			it.query.Cursor = page.NextCursor
+			it.done = page.NextCursor == 0
+		} else {
+			it.query.Page = page.Page + 1
+			it.done = len(page.Data) < it.query.Limit
+		}
+	}
+	return true
+}
+
+// User returns the current user.
+func (it *UserIterator) User() User {
+	return it.page[it.index]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *UserIterator) Err() error {
+	return it.err
+}
+
+// AllUsers returns every user matching query.
+func (c *Client) AllUsers(ctx context.Context, query UserQuery) ([]User, error) {
+	var users []User
+	it := c.Users(ctx, query)
+	for it.Next() {
+		users = append(users, it.User())
+	}
+	return users, it.Err()
+}
+
+// CreateUser creates a user, with the profile image read from image unless it
+// is nil.
+func (c *Client) CreateUser(ctx context.Context, user NewUser, image io.Reader) error {
+	payload, err := json.Marshal(struct {
+		FirstName    string            `json:"firstName"`
+		LastName     string            `json:"lastName"`
+		Email        string            `json:"email"`
+		Password     string            `json:"password,omitempty"`
+		EmployeeCode string            `json:"employeeCode,omitempty"`
+		Role         string            `json:"role"`
+		Fields       map[string]string `json:"fields,omitempty"`
+	}{user.FirstName, user.LastName, user.Email, user.Password, user.EmployeeCode, strconv.FormatInt(user.RoleID, 10), user.Fields})
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("json", string(payload)); err != nil {
+		return err
+	}
+	if image != nil {
+		part, err := form.CreateFormFile("image", "image")
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, image); err != nil {
+			return err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/user", nil, nil)
+	if err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(&body)
+	req.ContentLength = int64(body.Len())
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return c.send(req, nil)
+}
+
+// UpdateUser replaces the data of a user. Its password and employee code are
+// kept when empty and its role changes to that of user.Role.ID unless it is 0.
+// Send a PUT with an explicit empty employeeCode to clear it.
+func (c *Client) UpdateUser(ctx context.Context, id int64, user User) (*User, error) {
+	var res userResponse
+	if err := c.call(ctx, http.MethodPut, fmt.Sprintf("/user/%d", id), nil, user, &res); err != nil {
+		return nil, err
+	}
+	return &res.User, nil
+}
+
+// DeactivateUser keeps a user from logging in, keeping its records.
+func (c *Client) DeactivateUser(ctx context.Context, id int64) error {
+	return c.call(ctx, http.MethodDelete, fmt.Sprintf("/user/%d", id), nil, nil, nil)
+}
+
+func (c *Client) ReactivateUser(ctx context.Context, id int64) error {
+	return c.call(ctx, http.MethodPut, fmt.Sprintf("/user/%d/reactivate", id), nil, nil, nil)
+}
+
+// SendInvitation mails again the invitation of a user without password.
+func (c *Client) SendInvitation(ctx context.Context, id int64) error {
+	return c.call(ctx, http.MethodPost, fmt.Sprintf("/user/%d/invitation", id), nil, nil, nil)
+}
+
+// Reports returns the users reporting to the user logged in.
+func (c *Client) Reports(ctx context.Context) ([]User, error) {
+	var res struct {
+		Data []User `json:"data"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/user/reports", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
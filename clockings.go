@@ -0,0 +1,129 @@
+package pentview
+
+import (
+	"encoding/json"
+	"go-pentview/services"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// closeForgottenClockings periodically closes the intervals left open longer
+// than CLOCKOUT_CUTOFF (12h by default) and notifies the user and its manager,
+// or the admins when it has none. The admins also hear of those closed inside
+// a closed pay period, which only they can reopen.
+func (s *Server) closeForgottenClockings() {
+	cutoff, err := time.ParseDuration(getEnvVar("CLOCKOUT_CUTOFF"))
+	if err != nil || cutoff <= 0 {
+		cutoff = 12 * time.Hour
+	}
+
+	ticker := time.NewTicker(15 * time.Minute)
+	defer ticker.Stop()
+	for {
+		closed, locked, err := s.repo.CloseForgottenClockings(time.Now(), cutoff)
+		if err != nil {
+			log.Printf("closing forgotten clockings failed: %s\n", err)
+		}
+		for _, clocking := range closed {
+			s.repo.Notify(clocking.UserID, "auto_clockout", clocking.Date)
+			if user, err := s.repo.GetUserById(clocking.UserID); err == nil && isManager(s.repo, user.ManagerID) {
+				s.repo.Notify(user.ManagerID, "auto_clockout_of", clocking.UserID, clocking.Date)
+			} else {
+				s.repo.NotifyAdmins("auto_clockout_of", clocking.UserID, clocking.Date)
+			}
+		}
+		for _, clocking := range locked {
+			s.repo.NotifyAdmins("auto_clockout_locked", clocking.UserID, clocking.Date)
+		}
+		<-ticker.C
+	}
+}
+
+func (s *Server) getClockingsToReview(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth, managers only see their reports
+		isValid, user_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, user_id) && !isManager(repo, user_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		var clockings []services.Clocking
+		if isAdmin(repo, user_id) {
+			clockings, _ = repo.AllClockingsToReview()
+		} else {
+			clockings, _ = repo.AllReportClockings(user_id, true)
+		}
+		if len(clockings) == 0 {
+			clockings = []services.Clocking{}
+		}
+		data := struct {
+			Data []services.Clocking `json:"data"`
+		}{Data: clockings}
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) reviewClocking(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, user_id := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		// Retrieve id
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Admins review every clocking, managers those of their reports
+		clocking, err := repo.GetClockingById(intid)
+		if err != nil {
+			writeMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if !canManage(repo, user_id, clocking.UserID) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		// Retrieve json
+		var body services.ClockingReview
+		if err := decodeJSON(w, r, &body); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Review clocking
+		clocking, err = repo.ReviewClocking(intid, body.Date)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response clocking
+		res := struct {
+			Message  string            `json:"message"`
+			Clocking services.Clocking `json:"clocking"`
+		}{localize(w, "clocking_reviewed"), *clocking}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	pentview "go-pentview"
+	"net/http"
+	"os"
+)
+
+func main() {
+	err := pentview.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("server closed\n")
+	} else if err != nil {
+		fmt.Printf("error starting server: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,173 @@
+package pentview
+
+import (
+	"encoding/json"
+	"go-pentview/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) createContract(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Retrieve json
+		var contract services.Contract
+		if err := decodeJSON(w, r, &contract); err != nil {
+			writeError(w, err)
+			return
+		}
+		contract.UserID = intid
+
+		// Create contract
+		contractCreated, err := repo.CreateContract(contract)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response contract
+		res := struct {
+			Message  string            `json:"message"`
+			Contract services.Contract `json:"contract"`
+		}{localize(w, "contract_created"), *contractCreated}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) getContracts(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Auth, users see their own contracts
+		isValid, user_id := s.authenticate(r)
+		if !isValid || user_id != intid && !canManage(repo, user_id, intid) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		contracts, _ := repo.AllContracts(intid)
+		if len(contracts) == 0 {
+			contracts = []services.Contract{}
+		}
+		data := struct {
+			Data []services.Contract `json:"data"`
+		}{Data: contracts}
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) deleteContract(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := repo.DeleteContract(intid); err != nil {
+			writeMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		writeMessage(w, http.StatusOK, "contract_deleted")
+	}
+}
+
+func (s *Server) getHourBalance(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, user_id := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		s.writeHourBalance(w, r, repo, user_id)
+	}
+}
+func (s *Server) getUserHourBalance(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Auth
+		isValid, user_id := s.authenticate(r)
+		if !isValid || !canManage(repo, user_id, intid) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		s.writeHourBalance(w, r, repo, intid)
+	}
+}
+
+// writeHourBalance responds the plus and minus hours of the user between the
+// from and to query parameters, the current month by default, computed in the
+// tz time zone.
+func (s *Server) writeHourBalance(w http.ResponseWriter, r *http.Request, repo *services.SQLiteRepository, user_id int64) {
+	query := r.URL.Query()
+	loc, err := time.LoadLocation(query.Get("tz"))
+	if err != nil {
+		writeError(w, badRequest("unknown_time_zone", query.Get("tz")))
+		return
+	}
+	now := time.Now().In(loc)
+	from, to := query.Get("from"), query.Get("to")
+	if from == "" {
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc).Format(time.DateOnly)
+	}
+	if to == "" {
+		to = now.Format(time.DateOnly)
+	}
+
+	balance, err := repo.GetHourBalance(user_id, from, to, loc)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(balance); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
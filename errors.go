@@ -0,0 +1,221 @@
+package pentview
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go-pentview/blobs"
+	"go-pentview/i18n"
+	"go-pentview/images"
+	"go-pentview/services"
+	"go-pentview/validate"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// requestIDHeader carries the id of a request, taken from the client when it
+// sends a sensible one, so errors can be traced in the logs.
+const requestIDHeader = "X-Request-Id"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+const unknownFieldPrefix = "json: unknown field "
+
+// APIError is the body of every error response. Code is stable and meant for
+// clients, Message for people.
+type APIError struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+	args      []any
+}
+
+// FieldError tells which field of the request body is wrong and which rule
+// it breaks.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	param   string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// statusCode is the code of errors only described by their status, like
+// bad_request or not_found.
+func statusCode(status int) string {
+	return strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
+
+// errorStatuses maps the errors of the services to their status and code.
+var errorStatuses = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{services.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{services.ErrNotActivated, http.StatusForbidden, "not_activated"},
+	{services.ErrAlreadyClocked, http.StatusConflict, "already_clocked"},
+	{services.ErrNotExists, http.StatusNotFound, "not_found"},
+	{sql.ErrNoRows, http.StatusNotFound, "not_found"},
+	{services.ErrDuplicate, http.StatusConflict, "duplicate"},
+	{services.ErrUpdateFailed, http.StatusConflict, "update_failed"},
+	{services.ErrDeleteFailed, http.StatusConflict, "delete_failed"},
+	{services.ErrInactive, http.StatusForbidden, "inactive"},
+	{services.ErrLastAdmin, http.StatusConflict, "last_admin"},
+	{services.ErrRoleInUse, http.StatusConflict, "role_in_use"},
+	{services.ErrPeriodClosed, http.StatusConflict, "period_closed"},
+	{services.ErrInvalidToken, http.StatusBadRequest, "invalid_token"},
+	{images.ErrTooLarge, http.StatusRequestEntityTooLarge, "image_too_large"},
+	{images.ErrUnsupported, http.StatusUnsupportedMediaType, "unsupported_image"},
+	{images.ErrInvalidName, http.StatusBadRequest, "invalid_image_name"},
+	{images.ErrInvalidSize, http.StatusBadRequest, "invalid_image_size"},
+	{images.ErrDimensions, http.StatusRequestEntityTooLarge, "image_too_many_pixels"},
+	{images.ErrNotFound, http.StatusNotFound, "image_not_found"},
+	{blobs.ErrInvalidSignature, http.StatusForbidden, "invalid_signature"},
+}
+
+// toAPIError maps err to the response it deserves. Only the known errors, the
+// rules broken by the request and the failures decoding it are the fault of
+// the client. Any other error is internal: it is logged and its details are
+// not disclosed.
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	for _, known := range errorStatuses {
+		if errors.Is(err, known.err) {
+			return &APIError{Status: known.status, Code: known.code, Message: err.Error()}
+		}
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var sizeErr *http.MaxBytesError
+	var invalid validate.Errors
+	var rule *services.Invalid
+	var numErr *strconv.NumError
+	var sqliteErr sqlite3.Error
+	switch {
+	case errors.As(err, &rule):
+		return &APIError{Status: http.StatusBadRequest, Code: rule.Code, Message: rule.Error(), args: rule.Args}
+	case errors.As(err, &numErr):
+		// Path parameters not restricted to digits by their route
+		return &APIError{Status: http.StatusBadRequest, Code: "invalid_id", Message: err.Error()}
+	case errors.As(err, &invalid):
+		fields := make([]FieldError, len(invalid))
+		for i, e := range invalid {
+			fields[i] = FieldError{Field: e.Field, Rule: e.Rule, Message: e.Rule, param: strings.ReplaceAll(e.Param, " ", ", ")}
+		}
+		return &APIError{Status: http.StatusBadRequest, Code: "validation_failed", Message: err.Error(), Fields: fields}
+	case errors.As(err, &sizeErr):
+		return &APIError{Status: http.StatusRequestEntityTooLarge, Code: "body_too_large", Message: err.Error()}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return &APIError{Status: http.StatusBadRequest, Code: "invalid_json", Message: err.Error()}
+	case errors.As(err, &typeErr):
+		return &APIError{Status: http.StatusBadRequest, Code: "validation_failed", Message: err.Error(),
+			Fields: []FieldError{{Field: typeErr.Field, Rule: "type", Message: "type", param: typeErr.Type.String()}}}
+	case strings.HasPrefix(err.Error(), unknownFieldPrefix):
+		// encoding/json has no type for unknown fields, only this message
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), unknownFieldPrefix))
+		return &APIError{Status: http.StatusBadRequest, Code: "validation_failed", Message: err.Error(),
+			Fields: []FieldError{{Field: field, Rule: "unknown", Message: "unknown"}}}
+	case errors.As(err, &sqliteErr):
+		log.Printf("database error: %s\n", err)
+		return &APIError{Status: http.StatusInternalServerError, Code: "internal", Message: "error interno"}
+	}
+	log.Printf("unexpected error: %s\n", err)
+	return &APIError{Status: http.StatusInternalServerError, Code: "internal", Message: "error interno"}
+}
+
+// badRequest is a request rejected by the handlers themselves, like one with
+// a query parameter out of place. code is the key of its message in the
+// catalog, which args fill in.
+func badRequest(code string, args ...any) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: code, Message: i18n.T("en", code, args...), args: args}
+}
+
+// writeError responds err as an APIError with its status, its message and the
+// ones of its fields in the language of the response when the catalog has them.
+func writeError(w http.ResponseWriter, err error) {
+	apiErr := *toAPIError(err)
+	if i18n.Has(apiErr.Code) {
+		apiErr.Message = i18n.T(w.Header().Get("Content-Language"), apiErr.Code, apiErr.args...)
+	}
+	if len(apiErr.Fields) > 0 {
+		lang := w.Header().Get("Content-Language")
+		fields := make([]FieldError, len(apiErr.Fields))
+		for i, field := range apiErr.Fields {
+			if key := "rule_" + field.Rule; i18n.Has(key) && field.param != "" {
+				field.Message = i18n.T(lang, key, field.param)
+			} else if i18n.Has(key) {
+				field.Message = i18n.T(lang, key)
+			}
+			fields[i] = field
+		}
+		apiErr.Fields = fields
+	}
+	apiErr.RequestID = w.Header().Get(requestIDHeader)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(apiErr)
+}
+
+// language picks the language of the response: the one the user of the
+// session chose in its profile, or else the one negotiated from
+// Accept-Language.
+func language(r *http.Request, current session) string {
+	if current.valid && i18n.Supported(current.lang) {
+		return current.lang
+	}
+	return i18n.Negotiate(r.Header.Get("Accept-Language"))
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ServeHTTP routes the request after giving it an id and resolving its
+// session, and turns the panics of handlers into 500 responses instead of
+// dropped connections.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(requestIDHeader)
+	if !validRequestID.MatchString(id) {
+		id = newRequestID()
+	}
+	w.Header().Set(requestIDHeader, id)
+	current := s.resolveSession(r)
+	r = r.WithContext(context.WithValue(r.Context(), sessionKey{}, current))
+	w.Header().Set("Content-Language", language(r, current))
+
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		// Aborted on purpose, net/http handles it
+		if recovered == http.ErrAbortHandler {
+			panic(recovered)
+		}
+		log.Printf("panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, id, recovered, debug.Stack())
+		writeError(w, &APIError{Status: http.StatusInternalServerError, Code: "internal", Message: "error interno"})
+	}()
+	s.Router.ServeHTTP(w, r)
+}
@@ -0,0 +1,90 @@
+package pentview
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-pentview/i18n"
+	"go-pentview/services"
+	"go/ast"
+	"go/parser"
+	gotoken "go/token"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestToAPIError(t *testing.T) {
+	_, numErr := strconv.ParseInt("abc", 10, 64)
+	for _, test := range []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{services.ErrAlreadyClocked, http.StatusConflict, "already_clocked"},
+		{fmt.Errorf("%w: in", services.ErrAlreadyClocked), http.StatusConflict, "already_clocked"},
+		{sql.ErrNoRows, http.StatusNotFound, "not_found"},
+		{&services.Invalid{Code: "invalid_limit"}, http.StatusBadRequest, "invalid_limit"},
+		{badRequest("invalid_order"), http.StatusBadRequest, "invalid_order"},
+		{numErr, http.StatusBadRequest, "invalid_id"},
+		{errors.New("disk full"), http.StatusInternalServerError, "internal"},
+	} {
+		apiErr := toAPIError(test.err)
+		if apiErr.Status != test.status || apiErr.Code != test.code {
+			t.Errorf("%v: got %d %s, want %d %s", test.err, apiErr.Status, apiErr.Code, test.status, test.code)
+		}
+	}
+	if apiErr := toAPIError(errors.New("disk full")); apiErr.Message == "disk full" {
+		t.Error("the message of an unknown error is disclosed")
+	}
+}
+
+func TestWriteErrorTranslatesRules(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Language", "es")
+	writeError(w, &services.Invalid{Code: "role_not_found", Args: []any{7}})
+
+	var body APIError
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || body.Code != "role_not_found" || body.Message != "el rol 7 no existe" {
+		t.Errorf("got %d %+v", w.Code, body)
+	}
+}
+
+// TestRuleCodesInCatalog checks that the codes of every rule error, as given
+// to invalid, badRequest or services.Invalid, have a message in the catalog.
+func TestRuleCodesInCatalog(t *testing.T) {
+	fset := gotoken.NewFileSet()
+	for _, dir := range []string{".", "services", "exports"} {
+		packages, err := parser.ParseDir(fset, dir, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pkg := range packages {
+			ast.Inspect(pkg, func(node ast.Node) bool {
+				var code ast.Expr
+				switch node := node.(type) {
+				case *ast.CallExpr:
+					if name, ok := node.Fun.(*ast.Ident); ok && (name.Name == "invalid" || name.Name == "badRequest") && len(node.Args) > 0 {
+						code = node.Args[0]
+					}
+				case *ast.KeyValueExpr:
+					if key, ok := node.Key.(*ast.Ident); ok && key.Name == "Code" {
+						code = node.Value
+					}
+				}
+				if literal, ok := code.(*ast.BasicLit); ok && literal.Kind == gotoken.STRING {
+					key, _ := strconv.Unquote(literal.Value)
+					if !i18n.Has(key) {
+						t.Errorf("%s: %s is not in the catalog", fset.Position(literal.Pos()), key)
+					}
+				}
+				return true
+			})
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package pentview
+
+import (
+	"fmt"
+	"go-pentview/exports"
+	"go-pentview/services"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportOptions are the query parameters shared by every export endpoint:
+// format (csv or xlsx), from and to (YYYY-MM-DD), user, role, team, columns
+// (comma separated keys, custom fields as field.<key>), lang (es or en,
+// defaults to the language of the response) and tz (IANA name, defaults to
+// UTC).
+type exportOptions struct {
+	format   exports.Format
+	filter   services.ClockingFilter
+	columns  []string
+	lang     string
+	location *time.Location
+}
+
+func parseExportOptions(w http.ResponseWriter, r *http.Request) (*exportOptions, error) {
+	query := r.URL.Query()
+	var (
+		opts exportOptions
+		ok   bool
+		err  error
+	)
+
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if opts.format, ok = exports.Formats[format]; !ok {
+		return nil, badRequest("unsupported_format", format)
+	}
+
+	opts.filter.From = query.Get("from")
+	opts.filter.To = query.Get("to")
+	if user := query.Get("user"); user != "" {
+		if opts.filter.UserID, err = strconv.ParseInt(user, 10, 64); err != nil {
+			return nil, badRequest("not_an_id", "user")
+		}
+	}
+	if role := query.Get("role"); role != "" {
+		if opts.filter.RoleID, err = strconv.ParseInt(role, 10, 64); err != nil {
+			return nil, badRequest("not_an_id", "role")
+		}
+	}
+	if team := query.Get("team"); team != "" {
+		if opts.filter.TeamID, err = strconv.ParseInt(team, 10, 64); err != nil {
+			return nil, badRequest("not_an_id", "team")
+		}
+	}
+
+	if columns := query.Get("columns"); columns != "" {
+		opts.columns = strings.Split(columns, ",")
+	}
+
+	opts.lang = query.Get("lang")
+	if opts.lang == "" {
+		opts.lang = w.Header().Get("Content-Language")
+	}
+
+	if opts.location, err = time.LoadLocation(query.Get("tz")); err != nil {
+		return nil, badRequest("unknown_time_zone", query.Get("tz"))
+	}
+	return &opts, nil
+}
+
+// withFields appends to columns those of the custom fields.
+func withFields[T any](repo *services.SQLiteRepository, columns []exports.Column[T], user func(T) services.User) []exports.Column[T] {
+	fields, _ := repo.AllFields()
+	return append(slices.Clone(columns), exports.FieldColumns(fields, user)...)
+}
+
+// writeExport streams the rows produced by each as a table. Once the first row
+// is written the status can no longer change, so later errors are only logged.
+func writeExport[T any](w http.ResponseWriter, name string, all []exports.Column[T], opts *exportOptions, each func(fn func(T) error) error) {
+	columns, err := exports.Select(all, opts.columns)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", opts.format.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", name, opts.format.Extension))
+
+	table := opts.format.New(w, name)
+	if err := table.WriteRow(exports.Header(columns, opts.lang)); err != nil {
+		log.Printf("export %s failed: %s\n", name, err)
+		return
+	}
+	err = each(func(v T) error {
+		return table.WriteRow(exports.Row(columns, v))
+	})
+	if err != nil {
+		log.Printf("export %s failed: %s\n", name, err)
+		return
+	}
+	if err := table.Close(); err != nil {
+		log.Printf("export %s failed: %s\n", name, err)
+	}
+}
+
+func (s *Server) exportClockings(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Auth, managers only export their reports
+		isValid, user_id := s.authenticate(r)
+		admin := isValid && isAdmin(repo, user_id)
+		if !isValid || !admin && !isManager(repo, user_id) {
+			w.Header().Set("Content-Type", "application/json")
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		opts, err := parseExportOptions(w, r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, err)
+			return
+		}
+		if !admin {
+			opts.filter.ManagerID = user_id
+		}
+
+		columns := withFields(repo, exports.ClockingColumns, func(c services.ClockingRow) services.User { return c.User })
+		writeExport(w, "clockings", columns, opts, func(fn func(services.ClockingRow) error) error {
+			return repo.EachClocking(opts.filter, opts.location, fn)
+		})
+	}
+}
+func (s *Server) exportTimesheets(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Auth, managers only export their reports
+		isValid, user_id := s.authenticate(r)
+		admin := isValid && isAdmin(repo, user_id)
+		if !isValid || !admin && !isManager(repo, user_id) {
+			w.Header().Set("Content-Type", "application/json")
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		opts, err := parseExportOptions(w, r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, err)
+			return
+		}
+		if !admin {
+			opts.filter.ManagerID = user_id
+		}
+
+		columns := withFields(repo, exports.TimesheetColumns, func(d services.TimesheetDay) services.User { return d.User })
+		writeExport(w, "timesheets", columns, opts, func(fn func(services.TimesheetDay) error) error {
+			return repo.EachTimesheetDay(opts.filter, opts.location, fn)
+		})
+	}
+}
+
+func (s *Server) exportPayroll(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		opts, err := parseExportOptions(w, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		layout := r.URL.Query().Get("layout")
+		exporter, ok := exports.GetExporter(layout)
+		if !ok {
+			writeError(w, badRequest("unknown_layout", layout, strings.Join(exports.ExporterNames(), ", ")))
+			return
+		}
+		dailyHours, err := strconv.ParseFloat(getEnvVar("PAYROLL_DAILY_HOURS"), 64)
+		if err != nil || dailyHours <= 0 {
+			dailyHours = 8
+		}
+
+		w.Header().Set("Content-Type", exporter.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", layout, exporter.Extension()))
+		err = exporter.Export(w, func(fn func(exports.PayrollDay) error) error {
+			return repo.EachTimesheetDay(opts.filter, opts.location, func(day services.TimesheetDay) error {
+				return fn(exports.NewPayrollDay(day, dailyHours))
+			})
+		})
+		if err != nil {
+			log.Printf("export %s failed: %s\n", layout, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package exports
+
+import (
+	"go-pentview/services"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateTimeLayout = "2006-01-02 15:04:05"
+
+// Column is an exportable attribute of T with its header in every supported
+// language.
+type Column[T any] struct {
+	Key     string
+	Headers map[string]string
+	Numeric bool
+	Value   func(T) string
+}
+
+// Header returns the header row in lang, falling back to Spanish.
+func Header[T any](columns []Column[T], lang string) []Cell {
+	cells := make([]Cell, len(columns))
+	for i, column := range columns {
+		header, ok := column.Headers[lang]
+		if !ok {
+			header = column.Headers["es"]
+		}
+		cells[i] = Cell{Value: header}
+	}
+	return cells
+}
+
+func Row[T any](columns []Column[T], v T) []Cell {
+	cells := make([]Cell, len(columns))
+	for i, column := range columns {
+		cells[i] = Cell{Value: column.Value(v), Numeric: column.Numeric}
+	}
+	return cells
+}
+
+// Select returns the columns named by keys in that order, or all of them when
+// keys is empty.
+func Select[T any](columns []Column[T], keys []string) ([]Column[T], error) {
+	if len(keys) == 0 {
+		return columns, nil
+	}
+	selected := make([]Column[T], 0, len(keys))
+	for _, key := range keys {
+		found := false
+		for _, column := range columns {
+			if column.Key == strings.TrimSpace(key) {
+				selected = append(selected, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, &services.Invalid{Code: "unknown_column", Args: []any{key}}
+		}
+	}
+	return selected, nil
+}
+
+func formatTime(t time.Time, fallback string) string {
+	if t.IsZero() {
+		return fallback
+	}
+	return t.Format(dateTimeLayout)
+}
+
+var ClockingColumns = []Column[services.ClockingRow]{
+	{Key: "id", Headers: map[string]string{"es": "ID", "en": "ID"}, Numeric: true, Value: func(c services.ClockingRow) string { return strconv.FormatInt(c.ClockingID, 10) }},
+	{Key: "user", Headers: map[string]string{"es": "Usuario", "en": "User"}, Numeric: true, Value: func(c services.ClockingRow) string { return strconv.FormatInt(c.UserID, 10) }},
+	{Key: "employeeCode", Headers: map[string]string{"es": "Código", "en": "Employee code"}, Value: func(c services.ClockingRow) string { return c.User.EmployeeCode }},
+	{Key: "firstName", Headers: map[string]string{"es": "Nombre", "en": "First name"}, Value: func(c services.ClockingRow) string { return c.User.Name }},
+	{Key: "lastName", Headers: map[string]string{"es": "Apellido", "en": "Last name"}, Value: func(c services.ClockingRow) string { return c.User.Last }},
+	{Key: "email", Headers: map[string]string{"es": "Correo", "en": "Email"}, Value: func(c services.ClockingRow) string { return c.User.Email }},
+	{Key: "role", Headers: map[string]string{"es": "Rol", "en": "Role"}, Value: func(c services.ClockingRow) string { return c.User.Role.Name }},
+	{Key: "type", Headers: map[string]string{"es": "Tipo", "en": "Type"}, Value: func(c services.ClockingRow) string { return c.Type }},
+	{Key: "register", Headers: map[string]string{"es": "Fecha", "en": "Date"}, Value: func(c services.ClockingRow) string { return formatTime(c.Time, c.Date) }},
+	{Key: "review", Headers: map[string]string{"es": "En revisión", "en": "Under review"}, Value: func(c services.ClockingRow) string { return strconv.FormatBool(c.Review) }},
+}
+
+var TimesheetColumns = []Column[services.TimesheetDay]{
+	{Key: "user", Headers: map[string]string{"es": "Usuario", "en": "User"}, Numeric: true, Value: func(d services.TimesheetDay) string { return strconv.FormatInt(d.User.UserID, 10) }},
+	{Key: "employeeCode", Headers: map[string]string{"es": "Código", "en": "Employee code"}, Value: func(d services.TimesheetDay) string { return d.User.EmployeeCode }},
+	{Key: "firstName", Headers: map[string]string{"es": "Nombre", "en": "First name"}, Value: func(d services.TimesheetDay) string { return d.User.Name }},
+	{Key: "lastName", Headers: map[string]string{"es": "Apellido", "en": "Last name"}, Value: func(d services.TimesheetDay) string { return d.User.Last }},
+	{Key: "email", Headers: map[string]string{"es": "Correo", "en": "Email"}, Value: func(d services.TimesheetDay) string { return d.User.Email }},
+	{Key: "role", Headers: map[string]string{"es": "Rol", "en": "Role"}, Value: func(d services.TimesheetDay) string { return d.User.Role.Name }},
+	{Key: "day", Headers: map[string]string{"es": "Día", "en": "Day"}, Value: func(d services.TimesheetDay) string { return d.Day }},
+	{Key: "hours", Headers: map[string]string{"es": "Horas", "en": "Hours"}, Numeric: true, Value: func(d services.TimesheetDay) string { return strconv.FormatFloat(d.Hours, 'f', 2, 64) }},
+	{Key: "expected", Headers: map[string]string{"es": "Horas previstas", "en": "Expected hours"}, Numeric: true, Value: func(d services.TimesheetDay) string { return strconv.FormatFloat(d.Expected, 'f', 2, 64) }},
+	{Key: "balance", Headers: map[string]string{"es": "Saldo", "en": "Balance"}, Numeric: true, Value: func(d services.TimesheetDay) string { return strconv.FormatFloat(d.Balance, 'f', 2, 64) }},
+	{Key: "intervals", Headers: map[string]string{"es": "Intervalos", "en": "Intervals"}, Numeric: true, Value: func(d services.TimesheetDay) string { return strconv.Itoa(d.Intervals) }},
+	{Key: "open", Headers: map[string]string{"es": "Sin salida", "en": "Missing out"}, Value: func(d services.TimesheetDay) string { return strconv.FormatBool(d.Open) }},
+}
+
+// FieldColumns returns a column per custom field, keyed field.<key> and headed
+// by its label, reading the value from the user that user returns.
+func FieldColumns[T any](fields []services.Field, user func(T) services.User) []Column[T] {
+	columns := make([]Column[T], len(fields))
+	for i, field := range fields {
+		key := field.Key
+		columns[i] = Column[T]{
+			Key:     "field." + field.Key,
+			Headers: map[string]string{"es": field.Label, "en": field.Label},
+			Numeric: field.Type == "number",
+			Value:   func(v T) string { return user(v).Fields[key] },
+		}
+	}
+	return columns
+}
@@ -0,0 +1,261 @@
+package exports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"go-pentview/services"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	CategoryRegular  = "REG"
+	CategoryOvertime = "EXT"
+)
+
+// HourCategory is an amount of hours paid under the same payroll concept.
+type HourCategory struct {
+	Code  string
+	Hours float64
+}
+
+// PayrollDay is a timesheet day split into hour categories.
+type PayrollDay struct {
+	services.TimesheetDay
+	Categories []HourCategory
+}
+
+// NewPayrollDay pays as regular the hours up to dailyHours and the rest as
+// overtime. When a contract is in force its expected hours replace dailyHours,
+// and contracts not eligible for overtime pay every hour as regular.
+func NewPayrollDay(day services.TimesheetDay, dailyHours float64) PayrollDay {
+	if day.Contract != nil {
+		dailyHours = day.Expected
+		if !day.Contract.Overtime {
+			dailyHours = day.Hours
+		}
+	}
+	regular := min(day.Hours, dailyHours)
+	return PayrollDay{
+		TimesheetDay: day,
+		Categories: []HourCategory{
+			{Code: CategoryRegular, Hours: regular},
+			{Code: CategoryOvertime, Hours: day.Hours - regular},
+		},
+	}
+}
+
+// Exporter writes payroll days in the layout expected by a payroll provider.
+// The days are pulled through each so they can be streamed.
+type Exporter interface {
+	ContentType() string
+	Extension() string
+	Export(w io.Writer, each func(fn func(PayrollDay) error) error) error
+}
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]Exporter{
+		"payroll-csv": payrollCSV{},
+		"fixed-width": fixedWidth{},
+	}
+)
+
+// Register makes an exporter available under name, replacing any previous one.
+func Register(name string, exporter Exporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	exporters[name] = exporter
+}
+
+func GetExporter(name string) (Exporter, bool) {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	exporter, ok := exporters[name]
+	return exporter, ok
+}
+
+func ExporterNames() []string {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func employeeCode(user services.User) string {
+	if user.EmployeeCode != "" {
+		return user.EmployeeCode
+	}
+	return strconv.FormatInt(user.UserID, 10)
+}
+
+// payrollCSV writes one semicolon separated line per employee, day and hour
+// category: code;date;category;hours.
+type payrollCSV struct{}
+
+func (payrollCSV) ContentType() string { return "text/csv; charset=utf-8" }
+func (payrollCSV) Extension() string   { return "csv" }
+
+func (payrollCSV) Export(w io.Writer, each func(fn func(PayrollDay) error) error) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	if err := cw.Write([]string{"employee_code", "date", "category", "hours"}); err != nil {
+		return err
+	}
+	err := each(func(day PayrollDay) error {
+		for _, category := range day.Categories {
+			if category.Hours == 0 {
+				continue
+			}
+			record := []string{escapeFormula(employeeCode(day.User)), day.Day, category.Code, strconv.FormatFloat(category.Hours, 'f', 2, 64)}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// fixedWidth writes one 27 characters record per employee, day and hour
+// category: code (10, left aligned), date (8, YYYYMMDD), category (3) and
+// hundredths of hour (6, zero padded). Employee codes are validated to fit; a
+// longer one would be cut and could match another, so it stops the export.
+type fixedWidth struct{}
+
+func (fixedWidth) ContentType() string { return "text/plain; charset=utf-8" }
+func (fixedWidth) Extension() string   { return "txt" }
+
+func (fixedWidth) Export(w io.Writer, each func(fn func(PayrollDay) error) error) error {
+	return each(func(day PayrollDay) error {
+		for _, category := range day.Categories {
+			if category.Hours == 0 {
+				continue
+			}
+			code := employeeCode(day.User)
+			if len(code) > 10 {
+				return fmt.Errorf("employee code %q is longer than 10 characters", code)
+			}
+			_, err := fmt.Fprintf(w, "%-10s%s%-3.3s%06d\r\n", code, strings.ReplaceAll(day.Day, "-", ""), category.Code, int(category.Hours*100+0.5))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PayrollTotals is the data available to the footer of a template layout.
+type PayrollTotals struct {
+	Days  int
+	Hours float64
+}
+
+// TemplateExporter renders a layout defined with text/template. The template
+// may define "header", executed once before the days, "line", executed for
+// every day, and "footer", executed with the PayrollTotals.
+type TemplateExporter struct {
+	Template    *template.Template
+	contentType string
+	extension   string
+}
+
+var templateFuncs = template.FuncMap{
+	"code": employeeCode,
+	// pad left aligns s in n characters, truncating it when longer
+	"pad": func(n int, s string) string { return fmt.Sprintf("%-*.*s", n, n, s) },
+	// zpad right aligns s in n characters filling with zeros
+	"zpad": func(n int, s string) string { return fmt.Sprintf("%0*s", n, s) },
+	"hours": func(h float64) string {
+		return strconv.FormatFloat(h, 'f', 2, 64)
+	},
+	"hundredths": func(h float64) string {
+		return strconv.Itoa(int(h*100 + 0.5))
+	},
+	"date": func(layout string, day string) string {
+		t, err := time.Parse(time.DateOnly, day)
+		if err != nil {
+			return day
+		}
+		return t.Format(layout)
+	},
+}
+
+func NewTemplateExporter(name string, text string, contentType string, extension string) (*TemplateExporter, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl.Lookup("line") == nil {
+		return nil, errors.New("payroll template must define \"line\"")
+	}
+	return &TemplateExporter{Template: tmpl, contentType: contentType, extension: extension}, nil
+}
+
+func (e *TemplateExporter) ContentType() string { return e.contentType }
+func (e *TemplateExporter) Extension() string   { return e.extension }
+
+func (e *TemplateExporter) Export(w io.Writer, each func(fn func(PayrollDay) error) error) error {
+	if e.Template.Lookup("header") != nil {
+		if err := e.Template.ExecuteTemplate(w, "header", nil); err != nil {
+			return err
+		}
+	}
+
+	var totals PayrollTotals
+	err := each(func(day PayrollDay) error {
+		totals.Days++
+		totals.Hours += day.Hours
+		return e.Template.ExecuteTemplate(w, "line", day)
+	})
+	if err != nil {
+		return err
+	}
+
+	if e.Template.Lookup("footer") != nil {
+		return e.Template.ExecuteTemplate(w, "footer", totals)
+	}
+	return nil
+}
+
+// LoadTemplates registers every <name>.tmpl file of dir as an exporter named
+// after the file. The layouts are served as plain text files. A missing dir is
+// not an error.
+func LoadTemplates(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		exporter, err := NewTemplateExporter(name, string(text), "text/plain; charset=utf-8", "txt")
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		Register(name, exporter)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package exports
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Cell is a single value of a Table, Numeric cells are written as numbers where
+// the format makes a difference.
+type Cell struct {
+	Value   string
+	Numeric bool
+}
+
+// escapeFormula makes a spreadsheet read value as text when it would otherwise
+// be taken as a formula, like a name such as "=HYPERLINK(...)" entered by a
+// user, by prefixing it with an apostrophe.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// text is the value of a cell as written, numbers as they are and the rest
+// escaped from formulas.
+func (c Cell) text() string {
+	if c.Numeric {
+		return c.Value
+	}
+	return escapeFormula(c.Value)
+}
+
+// Table writes rows one at a time so exports can be streamed to the client.
+type Table interface {
+	WriteRow(cells []Cell) error
+	Close() error
+}
+
+type csvTable struct {
+	w *csv.Writer
+}
+
+func NewCSVTable(w io.Writer) Table {
+	return &csvTable{w: csv.NewWriter(w)}
+}
+
+func (t *csvTable) WriteRow(cells []Cell) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = cell.text()
+	}
+	return t.w.Write(record)
+}
+
+func (t *csvTable) Close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// xlsxTable writes a single sheet workbook. The parts before the sheet are
+// written on creation and the rows go straight into the zip entry of the sheet,
+// so nothing but the current row is kept in memory.
+type xlsxTable struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	row   int
+	err   error
+}
+
+func NewXLSXTable(w io.Writer, sheetName string) Table {
+	t := &xlsxTable{zw: zip.NewWriter(w)}
+
+	var name strings.Builder
+	xml.EscapeText(&name, []byte(sheetName))
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, name.String())},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, part := range parts {
+		if t.err = t.writePart(part.name, part.content); t.err != nil {
+			return t
+		}
+	}
+
+	t.sheet, t.err = t.zw.Create("xl/worksheets/sheet1.xml")
+	if t.err == nil {
+		_, t.err = io.WriteString(t.sheet, xlsxSheetStart)
+	}
+	return t
+}
+
+func (t *xlsxTable) writePart(name string, content string) error {
+	f, err := t.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, content)
+	return err
+}
+
+func (t *xlsxTable) WriteRow(cells []Cell) error {
+	if t.err != nil {
+		return t.err
+	}
+	t.row++
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, t.row)
+	for i, cell := range cells {
+		ref := fmt.Sprintf("%s%d", columnName(i), t.row)
+		if cell.Numeric && cell.Value != "" {
+			fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, cell.Value)
+			continue
+		}
+		fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+		xml.EscapeText(&b, []byte(cell.text()))
+		b.WriteString(`</t></is></c>`)
+	}
+	b.WriteString(`</row>`)
+
+	_, t.err = io.WriteString(t.sheet, b.String())
+	return t.err
+}
+
+func (t *xlsxTable) Close() error {
+	if t.err != nil {
+		return t.err
+	}
+	if _, err := io.WriteString(t.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return t.zw.Close()
+}
+
+// columnName returns the spreadsheet name of the zero based column i: A, B, ...
+// Z, AA, AB...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// Format describes how a Table is served for a given format query value.
+type Format struct {
+	ContentType string
+	Extension   string
+	New         func(w io.Writer, name string) Table
+}
+
+var Formats = map[string]Format{
+	"csv": {
+		ContentType: "text/csv; charset=utf-8",
+		Extension:   "csv",
+		New:         func(w io.Writer, name string) Table { return NewCSVTable(w) },
+	},
+	"xlsx": {
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		Extension:   "xlsx",
+		New:         NewXLSXTable,
+	},
+}
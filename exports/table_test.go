@@ -0,0 +1,29 @@
+package exports
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCSVTableEscapesFormulas(t *testing.T) {
+	var b bytes.Buffer
+	table := NewCSVTable(&b)
+	row := []Cell{
+		{Value: "=HYPERLINK(\"http://example.com\")"},
+		{Value: "+1"},
+		{Value: "-2"},
+		{Value: "@SUM(A1)"},
+		{Value: "Ana"},
+		{Value: "-7.50", Numeric: true},
+	}
+	if err := table.WriteRow(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "\"'=HYPERLINK(\"\"http://example.com\"\")\",'+1,'-2,'@SUM(A1),Ana,-7.50\n"
+	if got := b.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
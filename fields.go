@@ -0,0 +1,140 @@
+package pentview
+
+import (
+	"encoding/json"
+	"go-pentview/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) createField(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		// Retrieve json
+		var field services.Field
+		if err := decodeJSON(w, r, &field); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Create field
+		fieldCreated, err := repo.CreateField(field)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response field
+		res := struct {
+			Message string         `json:"message"`
+			Field   services.Field `json:"field"`
+		}{localize(w, "field_created"), *fieldCreated}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) getFields(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, _ := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		fields, _ := repo.AllFields()
+		if len(fields) == 0 {
+			fields = []services.Field{}
+		}
+		data := struct {
+			Data []services.Field `json:"data"`
+		}{Data: fields}
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) deleteField(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := repo.DeleteField(intid); err != nil {
+			writeMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		writeMessage(w, http.StatusOK, "field_deleted")
+	}
+}
+
+func (s *Server) setUserFields(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Retrieve json, an object of field keys and values
+		var values map[string]string
+		if err := decodeJSON(w, r, &values); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Set fields
+		user, err := repo.SetUserFields(intid, values)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response user
+		res := struct {
+			Message string        `json:"message"`
+			User    services.User `json:"user"`
+		}{localize(w, "fields_updated"), *user}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package pentview
+
+import (
+	"encoding/json"
+	"go-pentview/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) createDepartment(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		// Retrieve json
+		var department services.Department
+		if err := decodeJSON(w, r, &department); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Create department
+		departmentCreated, err := repo.CreateDepartment(department)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response department
+		res := struct {
+			Message    string              `json:"message"`
+			Department services.Department `json:"department"`
+		}{localize(w, "department_created"), *departmentCreated}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) getDepartments(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, _ := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		departments, _ := repo.AllDepartments()
+		if len(departments) == 0 {
+			departments = []services.Department{}
+		}
+		data := struct {
+			Data []services.Department `json:"data"`
+		}{Data: departments}
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) deleteDepartment(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := repo.DeleteDepartment(intid); err != nil {
+			writeMessage(w, http.StatusBadRequest, "department_not_deletable")
+			return
+		}
+
+		writeMessage(w, http.StatusOK, "department_deleted")
+	}
+}
+
+func (s *Server) createTeam(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		// Retrieve json
+		var team services.Team
+		if err := decodeJSON(w, r, &team); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Create team
+		teamCreated, err := repo.CreateTeam(team)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response team
+		res := struct {
+			Message string        `json:"message"`
+			Team    services.Team `json:"team"`
+		}{localize(w, "team_created"), *teamCreated}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) getTeams(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, _ := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		teams, _ := repo.AllTeams()
+		if len(teams) == 0 {
+			teams = []services.Team{}
+		}
+		data := struct {
+			Data []services.Team `json:"data"`
+		}{Data: teams}
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) deleteTeam(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := repo.DeleteTeam(intid); err != nil {
+			writeMessage(w, http.StatusBadRequest, "team_not_deletable")
+			return
+		}
+
+		writeMessage(w, http.StatusOK, "team_deleted")
+	}
+}
+
+func (s *Server) setHierarchy(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Retrieve json
+		var hierarchy services.Hierarchy
+		if err := decodeJSON(w, r, &hierarchy); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Set hierarchy
+		user, err := repo.SetHierarchy(intid, hierarchy)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response user
+		res := struct {
+			Message string        `json:"message"`
+			User    services.User `json:"user"`
+		}{localize(w, "hierarchy_updated"), *user}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// getReports lists the direct and indirect reports of the authenticated user.
+func (s *Server) getReports(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, user_id := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		users, _ := repo.AllReports(user_id)
+		if len(users) == 0 {
+			users = []services.User{}
+		}
+		data := struct {
+			Data []services.User `json:"data"`
+		}{Data: users}
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// getReportClockings lists the clockings of the reports of the authenticated
+// user, only those pending review with ?review=true.
+func (s *Server) getReportClockings(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, user_id := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		review := r.URL.Query().Get("review") == "true"
+		clockings, _ := repo.AllReportClockings(user_id, review)
+		if len(clockings) == 0 {
+			clockings = []services.Clocking{}
+		}
+		data := struct {
+			Data []services.Clocking `json:"data"`
+		}{Data: clockings}
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
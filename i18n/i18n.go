@@ -0,0 +1,66 @@
+// Package i18n translates the messages of the API, keyed by the same codes
+// clients see in error responses.
+package i18n
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Default is the language of clients that ask for none we speak.
+const Default = "es"
+
+// Languages are the languages messages are translated to.
+var Languages = []string{"es", "en"}
+
+// Supported tells whether lang is one of Languages.
+func Supported(lang string) bool {
+	return slices.Contains(Languages, lang)
+}
+
+// Has tells whether key is in the catalog.
+func Has(key string) bool {
+	_, ok := catalog[key]
+	return ok
+}
+
+// T returns the message of key in lang, formatted with args, falling back to
+// Default and then to the key itself.
+func T(lang string, key string, args ...any) string {
+	translations, ok := catalog[key]
+	if !ok {
+		return key
+	}
+	message, ok := translations[lang]
+	if !ok {
+		message = translations[Default]
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Negotiate picks the language of an Accept-Language header, like
+// "en-US,en;q=0.9,es;q=0.8", honoring its weights.
+func Negotiate(header string) string {
+	best, bestQ := Default, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if Supported(base) && q > bestQ {
+			best, bestQ = base, q
+		}
+	}
+	return best
+}
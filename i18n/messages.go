@@ -0,0 +1,149 @@
+package i18n
+
+// catalog holds every message by code and language.
+var catalog = map[string]map[string]string{
+	// Errors
+	"unauthorized":             {"es": "no autorizado", "en": "unauthorized"},
+	"invalid_credentials":      {"es": "usuario o contraseña incorrectos", "en": "wrong username or password"},
+	"not_activated":            {"es": "la cuenta aún no ha sido activada", "en": "the account has not been activated yet"},
+	"inactive":                 {"es": "el usuario está desactivado", "en": "the user is deactivated"},
+	"invalid_token":            {"es": "la invitación no es válida o ha expirado", "en": "the invitation is invalid or expired"},
+	"not_found":                {"es": "el registro no existe", "en": "the record does not exist"},
+	"duplicate":                {"es": "el registro ya existe", "en": "the record already exists"},
+	"update_failed":            {"es": "no se pudo actualizar el registro", "en": "the record could not be updated"},
+	"delete_failed":            {"es": "no se pudo eliminar el registro", "en": "the record could not be deleted"},
+	"last_admin":               {"es": "el último administrador no puede perder su rol ni ser desactivado", "en": "the last administrator cannot lose its role or be deactivated"},
+	"role_in_use":              {"es": "el rol está asignado a usuarios, indique reassign para moverlos a otro rol", "en": "the role is assigned to users, set reassign to move them to another role"},
+	"invalid_reassign":         {"es": "reassign debe ser el id de un rol", "en": "reassign must be a role id"},
+	"cannot_deactivate_self":   {"es": "no puede desactivarse a sí mismo", "en": "you cannot deactivate yourself"},
+	"department_not_deletable": {"es": "el departamento no existe o tiene equipos", "en": "the department does not exist or has teams"},
+	"team_not_deletable":       {"es": "el equipo no existe o tiene miembros", "en": "the team does not exist or has members"},
+	"period_closed":            {"es": "el periodo de pago está cerrado", "en": "the pay period is closed"},
+	"already_clocked":          {"es": "el último registro ya es de ese tipo", "en": "the last clocking already has that type"},
+	"image_required":           {"es": "falta la imagen", "en": "image is required"},
+	"image_too_large":          {"es": "la imagen es demasiado grande", "en": "the image is too large"},
+	"unsupported_image":        {"es": "la imagen debe ser PNG, JPEG o WebP", "en": "the image must be PNG, JPEG or WebP"},
+	"image_too_many_pixels":    {"es": "las dimensiones de la imagen son demasiado grandes", "en": "the image dimensions are too large"},
+	"invalid_image_name":       {"es": "nombre de imagen no válido", "en": "invalid image name"},
+	"invalid_image_size":       {"es": "tamaño de imagen no admitido", "en": "unsupported image size"},
+	"image_not_found":          {"es": "la imagen no existe", "en": "image not found"},
+	"image_read_failed":        {"es": "no se pudo leer la imagen", "en": "the image could not be read"},
+	"image_sign_failed":        {"es": "no se pudo firmar la imagen", "en": "the image could not be signed"},
+	"invalid_signature":        {"es": "firma no válida o expirada", "en": "invalid or expired signature"},
+	"invalid_json":             {"es": "el cuerpo de la petición no es JSON válido", "en": "the request body is not valid JSON"},
+	"validation_failed":        {"es": "hay campos no válidos", "en": "some fields are invalid"},
+	"body_too_large":           {"es": "el cuerpo de la petición es demasiado grande", "en": "the request body is too large"},
+	"route_not_found":          {"es": "ruta no encontrada", "en": "route not found"},
+	"method_not_allowed":       {"es": "método no permitido", "en": "method not allowed"},
+	"internal":                 {"es": "error interno", "en": "internal error"},
+
+	// Rules of the services and of the query parameters, see services.Invalid
+	"required":                     {"es": "%s es obligatorio", "en": "%s is required"},
+	"invalid_id":                   {"es": "el id debe ser un número", "en": "the id must be a number"},
+	"not_an_id":                    {"es": "%s debe ser un id", "en": "%s must be an id"},
+	"not_a_number":                 {"es": "%s debe ser un número", "en": "%s must be a number"},
+	"not_a_boolean":                {"es": "%s debe ser true o false", "en": "%s must be true or false"},
+	"not_an_option":                {"es": "%s debe ser uno de: %s", "en": "%s must be one of: %s"},
+	"invalid_date":                 {"es": "%s debe ser una fecha AAAA-MM-DD", "en": "%s must be formatted as YYYY-MM-DD"},
+	"invalid_datetime":             {"es": "%s debe ser una fecha y hora RFC 3339", "en": "%s must be an RFC 3339 date and time"},
+	"invalid_date_range":           {"es": "to debe ser posterior a from y como mucho un año después", "en": "to must be after from and at most one year later"},
+	"end_before_start":             {"es": "end no puede ser anterior a start", "en": "end must not be before start"},
+	"entitlement_target_required":  {"es": "indique un usuario o un rol", "en": "user or role is required"},
+	"entitlement_target_ambiguous": {"es": "la asignación debe ser de un usuario o de un rol, no de ambos", "en": "entitlement must target either a user or a role"},
+	"invalid_entitlement":          {"es": "los valores de la asignación no son válidos", "en": "invalid entitlement values"},
+	"zero_amount":                  {"es": "amount no puede ser cero", "en": "amount must not be zero"},
+	"invalid_weekly_hours":         {"es": "weeklyHours debe estar entre 0 y 168", "en": "weeklyHours must be between 0 and 168"},
+	"invalid_work_days":            {"es": "workDays debe estar entre 1 y 7", "en": "workDays must be between 1 and 7"},
+	"contract_overlap":             {"es": "el contrato se solapa con otro contrato del usuario", "en": "contract overlaps another contract of the user"},
+	"pattern_mismatch":             {"es": "%s no cumple el patrón %s", "en": "%s does not match %s"},
+	"invalid_field_key":            {"es": "key debe empezar por una letra y contener solo letras, dígitos y _", "en": "key must start with a letter and only contain letters, digits and _"},
+	"invalid_field_type":           {"es": "type debe ser uno de: %s", "en": "type must be one of: %s"},
+	"select_without_options":       {"es": "un campo select necesita opciones", "en": "a select needs options"},
+	"pattern_not_text":             {"es": "solo los campos de texto admiten un patrón", "en": "only text fields accept a pattern"},
+	"invalid_pattern":              {"es": "el patrón no es válido: %s", "en": "invalid pattern: %s"},
+	"unknown_custom_field":         {"es": "el campo %q no existe", "en": "field %q does not exist"},
+	"department_not_found":         {"es": "el departamento %d no existe", "en": "department %d does not exist"},
+	"team_not_found":               {"es": "el equipo %d no existe", "en": "team %d does not exist"},
+	"manager_not_found":            {"es": "el responsable %d no existe", "en": "manager %d does not exist"},
+	"self_manager":                 {"es": "un usuario no puede ser su propio responsable", "en": "a user cannot manage itself"},
+	"manager_is_report":            {"es": "el responsable no puede depender del usuario", "en": "manager cannot be one of the user's reports"},
+	"invalid_csv":                  {"es": "no se puede leer el CSV: %s", "en": "cannot read CSV: %s"},
+	"csv_missing_column":           {"es": "la cabecera del CSV debe incluir %q", "en": "CSV header must include %q"},
+	"password_too_short":           {"es": "la contraseña debe tener al menos 8 caracteres", "en": "password must have at least 8 characters"},
+	"invalid_frequency":            {"es": "frequency debe ser weekly, biweekly o monthly", "en": "frequency must be weekly, biweekly or monthly"},
+	"period_overlap":               {"es": "el periodo de pago se solapa con otro", "en": "pay period overlaps an existing one"},
+	"anonymize_active":             {"es": "solo se pueden anonimizar usuarios desactivados", "en": "only deactivated users can be anonymized"},
+	"purge_active":                 {"es": "solo se pueden eliminar usuarios desactivados", "en": "only deactivated users can be purged"},
+	"retention_pending":            {"es": "el usuario debe conservarse hasta el %s", "en": "user must be kept until %s"},
+	"admin_not_renamable":          {"es": "el rol %s no se puede renombrar", "en": "the %s role cannot be renamed"},
+	"admin_not_deletable":          {"es": "el rol %s no se puede eliminar", "en": "the %s role cannot be deleted"},
+	"reassign_to_deleted":          {"es": "no se puede reasignar usuarios al rol que se elimina", "en": "cannot reassign users to the role being deleted"},
+	"role_not_found":               {"es": "el rol %d no existe", "en": "role %d does not exist"},
+	"invalid_role":                 {"es": "role debe ser el id de un rol", "en": "role must be a role id"},
+	"invalid_sort":                 {"es": "no se puede ordenar por %q", "en": "cannot sort by %q"},
+	"cursor_needs_id_sort":         {"es": "cursor solo puede usarse ordenando por _id", "en": "cursor can only be used sorting by _id"},
+	"invalid_limit":                {"es": "limit debe estar entre 1 y 100", "en": "limit must be between 1 and 100"},
+	"invalid_status":               {"es": "status debe ser active, inactive o all", "en": "status must be active, inactive or all"},
+	"invalid_order":                {"es": "order debe ser asc o desc", "en": "order must be asc or desc"},
+	"invalid_form":                 {"es": "el formulario no es válido: %s", "en": "invalid form: %s"},
+	"unsupported_format":           {"es": "formato %q no admitido", "en": "unsupported format %q"},
+	"unknown_column":               {"es": "la columna %q no existe", "en": "unknown column %q"},
+	"unknown_layout":               {"es": "el formato de nómina %q no existe, disponibles: %s", "en": "unknown layout %q, available: %s"},
+	"unknown_time_zone":            {"es": "zona horaria %q desconocida", "en": "unknown time zone %q"},
+
+	// Field rules, see package validate
+	"rule_required":   {"es": "es obligatorio", "en": "is required"},
+	"rule_email":      {"es": "debe ser un email válido", "en": "must be a valid email"},
+	"rule_date":       {"es": "debe ser una fecha AAAA-MM-DD", "en": "must be a YYYY-MM-DD date"},
+	"rule_datetime":   {"es": "debe ser una fecha y hora RFC 3339", "en": "must be an RFC 3339 date and time"},
+	"rule_oneof":      {"es": "debe ser uno de: %s", "en": "must be one of: %s"},
+	"rule_min":        {"es": "debe ser al menos %s", "en": "must be at least %s"},
+	"rule_max":        {"es": "debe ser como mucho %s", "en": "must be at most %s"},
+	"rule_min_length": {"es": "debe tener al menos %s caracteres", "en": "must have at least %s characters"},
+	"rule_max_length": {"es": "debe tener como mucho %s caracteres", "en": "must have at most %s characters"},
+	"rule_type":       {"es": "debe ser de tipo %s", "en": "must be of type %s"},
+	"rule_unknown":    {"es": "no es un campo admitido", "en": "is not a known field"},
+
+	// Successes
+	"user_created":          {"es": "Usuario creado", "en": "User created"},
+	"user_created_invited":  {"es": "Usuario creado, invitación enviada", "en": "User created, invitation sent"},
+	"user_updated":          {"es": "Usuario actualizado correctamente", "en": "User updated"},
+	"user_deactivated":      {"es": "Usuario desactivado", "en": "User deactivated"},
+	"user_reactivated":      {"es": "Usuario reactivado", "en": "User reactivated"},
+	"user_purged":           {"es": "Usuario eliminado definitivamente", "en": "User permanently deleted"},
+	"user_anonymized":       {"es": "Usuario anonimizado", "en": "User anonymized"},
+	"invitation_sent":       {"es": "Invitación enviada", "en": "Invitation sent"},
+	"profile_image_updated": {"es": "Imagen de perfil actualizada", "en": "Profile image updated"},
+	"profile_image_deleted": {"es": "Imagen de perfil eliminada", "en": "Profile image removed"},
+	"role_created":          {"es": "Rol creado correctamente", "en": "Role created"},
+	"role_updated":          {"es": "Rol actualizado correctamente", "en": "Role updated"},
+	"role_deleted":          {"es": "Rol eliminado", "en": "Role deleted"},
+	"clocking_created":      {"es": "Clocking registrado", "en": "Clocking registered"},
+	"clocking_reviewed":     {"es": "Clocking revisado", "en": "Clocking reviewed"},
+	"adjustment_created":    {"es": "Ajuste registrado", "en": "Adjustment registered"},
+	"entitlement_set":       {"es": "Asignación registrada", "en": "Entitlement set"},
+	"entitlement_deleted":   {"es": "Asignación eliminada", "en": "Entitlement deleted"},
+	"notification_read":     {"es": "Notificación leída", "en": "Notification read"},
+	"pay_period_created":    {"es": "Periodo creado correctamente", "en": "Pay period created"},
+	"pay_period_closed":     {"es": "Periodo cerrado", "en": "Pay period closed"},
+	"pay_period_reopened":   {"es": "Periodo reabierto", "en": "Pay period reopened"},
+	"department_created":    {"es": "Departamento creado correctamente", "en": "Department created"},
+	"department_deleted":    {"es": "Departamento eliminado", "en": "Department deleted"},
+	"team_created":          {"es": "Equipo creado correctamente", "en": "Team created"},
+	"team_deleted":          {"es": "Equipo eliminado", "en": "Team deleted"},
+	"hierarchy_updated":     {"es": "Jerarquía actualizada", "en": "Hierarchy updated"},
+	"field_created":         {"es": "Campo creado correctamente", "en": "Field created"},
+	"field_deleted":         {"es": "Campo eliminado", "en": "Field deleted"},
+	"fields_updated":        {"es": "Campos actualizados", "en": "Fields updated"},
+	"contract_created":      {"es": "Contrato creado correctamente", "en": "Contract created"},
+	"contract_deleted":      {"es": "Contrato eliminado", "en": "Contract deleted"},
+
+	// Notifications
+	"auto_clockout":        {"es": "Salida automática registrada el %s, pendiente de revisión", "en": "Automatic clock out registered on %s, pending review"},
+	"auto_clockout_of":     {"es": "Salida automática registrada para el usuario %d el %s", "en": "Automatic clock out registered for user %d on %s"},
+	"auto_clockout_locked": {"es": "Salida automática registrada para el usuario %d el %s dentro de un periodo cerrado, reábrelo para revisarla", "en": "Automatic clock out registered for user %d on %s inside a closed pay period, reopen it to review it"},
+
+	// Mails
+	"invitation_subject": {"es": "Activa tu cuenta", "en": "Activate your account"},
+	"invitation_body":    {"es": "Hola %s,\n\nSe ha creado tu cuenta en Pentview Control de Horas. Para elegir tu contraseña ingresa a:\n\n%s\n\nEl enlace puede usarse una sola vez y caduca el %s.\n", "en": "Hello %s,\n\nYour Pentview Time Tracking account has been created. To choose your password go to:\n\n%s\n\nThe link can be used only once and expires on %s.\n"},
+}
@@ -0,0 +1,146 @@
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// palette holds the avatar backgrounds, all dark enough for white initials.
+var palette = []color.RGBA{
+	{0xE5, 0x39, 0x35, 0xFF},
+	{0xD8, 0x1B, 0x60, 0xFF},
+	{0x8E, 0x24, 0xAA, 0xFF},
+	{0x5E, 0x35, 0xB1, 0xFF},
+	{0x39, 0x49, 0xAB, 0xFF},
+	{0x1E, 0x88, 0xE5, 0xFF},
+	{0x00, 0x89, 0x7B, 0xFF},
+	{0x43, 0xA0, 0x47, 0xFF},
+	{0x6D, 0x4C, 0x41, 0xFF},
+	{0xF4, 0x51, 0x1E, 0xFF},
+	{0x54, 0x6E, 0x7A, 0xFF},
+	{0x00, 0x83, 0x8F, 0xFF},
+}
+
+// maxAvatars bounds the rendered avatars kept in memory.
+const maxAvatars = 1024
+
+var boldFont, _ = opentype.Parse(gobold.TTF)
+
+// Avatar is the image shown for a user without an uploaded one: its initials
+// on a color derived from its id, so the same user always looks the same.
+type Avatar struct {
+	Initials string
+	Color    color.RGBA
+}
+
+func NewAvatar(id int64, name, last string) Avatar {
+	initials := initial(name) + initial(last)
+	if initials == "" {
+		initials = "?"
+	}
+	// Multiplied so consecutive ids get distant colors
+	index := (uint64(id) * 7) % uint64(len(palette))
+	return Avatar{Initials: initials, Color: palette[index]}
+}
+
+func initial(s string) string {
+	r, _ := utf8.DecodeRuneInString(strings.TrimSpace(s))
+	if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return ""
+	}
+	return string(unicode.ToUpper(r))
+}
+
+// ETag identifies the rendering of the avatar, which changes with the name.
+func (a Avatar) ETag(size int, format string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%v|%d|%s", a.Initials, a.Color, size, format)))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// SVG renders the avatar as a square SVG of size pixels.
+func (a Avatar) SVG(size int) []byte {
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="%[1]d" viewBox="0 0 %[1]d %[1]d">`+
+		`<rect width="100%%" height="100%%" fill="#%02x%02x%02x"/>`+
+		`<text x="50%%" y="50%%" dy=".35em" text-anchor="middle" font-family="Helvetica, Arial, sans-serif" font-weight="bold" font-size="%d" fill="#fff">%s</text>`+
+		`</svg>`, size, a.Color.R, a.Color.G, a.Color.B, size*2/5, html.EscapeString(a.Initials)))
+}
+
+// PNG renders the avatar as a square PNG of size pixels.
+func (a Avatar) PNG(size int) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(a.Color), image.Point{}, draw.Src)
+
+	face, err := opentype.NewFace(boldFont, &opentype.FaceOptions{Size: float64(size) * 2 / 5, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	drawer := font.Drawer{Dst: img, Src: image.White, Face: face}
+	width := drawer.MeasureString(a.Initials)
+	capHeight := face.Metrics().CapHeight
+	drawer.Dot = fixed.Point26_6{
+		X: (fixed.I(size) - width) / 2,
+		Y: (fixed.I(size) + capHeight) / 2,
+	}
+	drawer.DrawString(a.Initials)
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Avatar returns the avatar rendered as format, svg or png, keeping it in
+// memory as the same avatars are asked for over and over by user lists.
+func (s *Store) Avatar(a Avatar, size int, format string) ([]byte, error) {
+	if !slices.Contains(Sizes, size) {
+		return nil, ErrInvalidSize
+	}
+	key := a.ETag(size, format)
+
+	s.mu.Lock()
+	b, ok := s.avatars[key]
+	s.mu.Unlock()
+	if ok {
+		return b, nil
+	}
+
+	switch format {
+	case "svg":
+		b = a.SVG(size)
+	case "png":
+		var err error
+		if b, err = a.PNG(size); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupported
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Renaming users leaves stale entries, so the cache is dropped when full
+	if len(s.avatars) >= maxAvatars {
+		clear(s.avatars)
+	}
+	s.avatars[key] = b
+	return b, nil
+}
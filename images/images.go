@@ -0,0 +1,266 @@
+// Package images stores uploaded profile images under content-addressed names
+// so that a client can never choose, and so traverse out of, the path written
+// to or read from.
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-pentview/blobs"
+	"image"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxSize is the largest image accepted, in bytes.
+const MaxSize = 5 << 20
+
+var (
+	ErrTooLarge    = fmt.Errorf("image exceeds %d MB", MaxSize>>20)
+	ErrUnsupported = errors.New("image must be PNG, JPEG or WebP")
+	ErrInvalidName = errors.New("invalid image name")
+	ErrNotFound    = errors.New("image not found")
+)
+
+// extensions maps the accepted sniffed content types to their file extension.
+var extensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+}
+
+// validName accepts plain file names only. Besides the generated names it lets
+// through those of images stored before names were generated, like nopfp.png.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// generatedName matches the names given by Prepare and their variants.
+var generatedName = regexp.MustCompile(`^[0-9a-f]{64}(-[0-9]+)?\.(png|jpg|webp)$`)
+
+// Store keeps images in Blobs.
+type Store struct {
+	Blobs blobs.Store
+
+	mu      sync.Mutex
+	avatars map[string][]byte
+}
+
+func NewStore(b blobs.Store) *Store {
+	return &Store{Blobs: b, avatars: map[string][]byte{}}
+}
+
+// Sniff returns the content type of an image from its first bytes, failing
+// with ErrUnsupported for anything but PNG, JPEG or WebP.
+func Sniff(b []byte) (string, error) {
+	contentType := http.DetectContentType(b)
+	if _, ok := extensions[contentType]; !ok {
+		return "", ErrUnsupported
+	}
+	return contentType, nil
+}
+
+// Upload is an image processed by Prepare and ready to be stored by Put.
+type Upload struct {
+	// Name is the SHA-256 of the upload with the extension of the stored
+	// format.
+	Name string
+
+	blobs []blob
+}
+
+// blob is an encoded image and the name it is stored under.
+type blob struct {
+	name string
+	data []byte
+}
+
+// Prepare validates and decodes the image read from r, turns it upright, crops
+// it to a square, scales it down to at most maxSide and re-encodes it without
+// metadata, along with a variant for each of Sizes. Nothing is stored yet.
+func (s *Store) Prepare(r io.Reader) (*Upload, error) {
+	b, err := io.ReadAll(io.LimitReader(r, MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > MaxSize {
+		return nil, ErrTooLarge
+	}
+	if _, err := Sniff(b); err != nil {
+		return nil, err
+	}
+	img, format, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(b)
+	upload := &Upload{Name: hex.EncodeToString(sum[:]) + extension(format)}
+	// Variants first, so the image is never found without them
+	for _, size := range Sizes {
+		if err := upload.add(variantName(upload.Name, size), thumbnail(img, size), format); err != nil {
+			return nil, err
+		}
+	}
+	if err := upload.add(upload.Name, thumbnail(img, maxSide), format); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// add encodes img as the blob called name.
+func (u *Upload) add(name string, img image.Image, format string) error {
+	var b bytes.Buffer
+	if err := encode(&b, img, format); err != nil {
+		return err
+	}
+	u.blobs = append(u.blobs, blob{name, b.Bytes()})
+	return nil
+}
+
+// write encodes img into the blob called name.
+func (s *Store) write(name string, img image.Image, format string) error {
+	var b bytes.Buffer
+	if err := encode(&b, img, format); err != nil {
+		return err
+	}
+	return s.Blobs.Put(name, b.Bytes(), http.DetectContentType(b.Bytes()))
+}
+
+// Put stores the image prepared in u. Storing the same image twice keeps a
+// single set of blobs.
+func (s *Store) Put(u *Upload) error {
+	if exists, err := s.Blobs.Exists(u.Name); err != nil || exists {
+		return err
+	}
+	for _, b := range u.blobs {
+		if err := s.Blobs.Put(b.name, b.data, http.DetectContentType(b.data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// variantName is the name of the size variant of the image called name.
+// Variants are always JPEG or PNG, whatever the original.
+func variantName(name string, size int) string {
+	ext := filepath.Ext(name)
+	if ext != ".jpg" {
+		ext = ".png"
+	}
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, filepath.Ext(name)), size, ext)
+}
+
+// Image is a stored image read whole.
+type Image struct {
+	*bytes.Reader
+	Name        string
+	ContentType string
+	ModTime     time.Time
+	// Immutable tells the name is content-addressed, so it always holds the
+	// same bytes and can be cached for good.
+	Immutable bool
+}
+
+// Resolve returns the name of the image called name, or of its size variant
+// when size is not zero. Variants missing, like those of images stored before
+// they were generated, are created from the image on first use.
+func (s *Store) Resolve(name string, size int) (string, error) {
+	if !validName.MatchString(name) {
+		return "", ErrInvalidName
+	}
+	if size == 0 {
+		return name, nil
+	}
+	if !slices.Contains(Sizes, size) {
+		return "", ErrInvalidSize
+	}
+	variant := variantName(name, size)
+	exists, err := s.Blobs.Exists(variant)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		if err := s.generate(name, variant, size); err != nil {
+			return "", err
+		}
+	}
+	return variant, nil
+}
+
+// Open returns the image called name, or its size variant when size is not
+// zero, with its sniffed content type.
+func (s *Store) Open(name string, size int) (*Image, error) {
+	name, err := s.Resolve(name, size)
+	if err != nil {
+		return nil, err
+	}
+	object, err := s.Blobs.Get(name)
+	if errors.Is(err, blobs.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Image{
+		Reader:      bytes.NewReader(object.Data),
+		Name:        name,
+		ContentType: http.DetectContentType(object.Data),
+		ModTime:     object.ModTime,
+		Immutable:   generatedName.MatchString(name),
+	}, nil
+}
+
+// SignedURL returns a URL the image, or its size variant, can be downloaded
+// from until expiry.
+func (s *Store) SignedURL(name string, size int, expiry time.Duration) (string, error) {
+	name, err := s.Resolve(name, size)
+	if err != nil {
+		return "", err
+	}
+	return s.Blobs.SignedURL(name, expiry)
+}
+
+// generate stores the size variant of the image called name.
+func (s *Store) generate(name, variant string, size int) error {
+	object, err := s.Blobs.Get(name)
+	if errors.Is(err, blobs.ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	img, format, err := decode(object.Data)
+	if err != nil {
+		return err
+	}
+	if format != "jpeg" {
+		format = "png"
+	}
+	return s.write(variant, thumbnail(img, size), format)
+}
+
+// Remove deletes the image called name and its variants. Missing images are
+// not an error.
+func (s *Store) Remove(name string) error {
+	if !validName.MatchString(name) {
+		return ErrInvalidName
+	}
+	names := []string{name}
+	for _, size := range Sizes {
+		names = append(names, variantName(name, size))
+	}
+	for _, name := range names {
+		if err := s.Blobs.Delete(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Sizes are the square thumbnails generated for every image, in pixels.
+var Sizes = []int{64, 128, 256}
+
+const (
+	// maxSide bounds the stored image, larger uploads are scaled down
+	maxSide = 1024
+	// maxPixels guards against images that decompress into huge bitmaps
+	maxPixels = 40_000_000
+)
+
+var (
+	ErrInvalidSize = fmt.Errorf("size must be one of %v", Sizes)
+	ErrDimensions  = errors.New("image dimensions are too large")
+)
+
+// decode reads an image checking first that its dimensions are reasonable.
+// JPEG images are turned upright following their EXIF orientation.
+func decode(b []byte) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrUnsupported, err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, "", ErrDimensions
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrUnsupported, err)
+	}
+	if format == "jpeg" {
+		img = orient(img, exifOrientation(b))
+	}
+	return img, format, nil
+}
+
+// thumbnail crops the centered square of img and scales it to side pixels,
+// never enlarging it.
+func thumbnail(img image.Image, side int) image.Image {
+	b := img.Bounds()
+	square := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-square)/2
+	y0 := b.Min.Y + (b.Dy()-square)/2
+	crop := image.Rect(x0, y0, x0+square, y0+square)
+
+	side = min(side, square)
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	return dst
+}
+
+// encode writes img as JPEG for JPEG sources and as PNG otherwise, so
+// transparency survives. Re-encoding leaves every metadata block behind.
+func encode(w io.Writer, img image.Image, format string) error {
+	if format == "jpeg" {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+	return png.Encode(w, img)
+}
+
+func extension(format string) string {
+	if format == "jpeg" {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+// orient applies an EXIF orientation (1 to 8) so the image is shown upright.
+func orient(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			// Source pixel shown at x, y
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, src.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// exifOrientation returns the orientation tag of the EXIF block of a JPEG, or
+// 1 when it has none.
+func exifOrientation(b []byte) int {
+	if len(b) < 4 || b[0] != 0xFF || b[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xFF {
+			return 1
+		}
+		marker := b[i+1]
+		length := int(binary.BigEndian.Uint16(b[i+2:]))
+		// Start of scan, the metadata segments are over
+		if marker == 0xDA || length < 2 || i+2+length > len(b) {
+			return 1
+		}
+		segment := b[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < entries; e++ {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
@@ -0,0 +1,134 @@
+package pentview
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-pentview/i18n"
+	"go-pentview/mailer"
+	"go-pentview/services"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// deliverMail sends the queued mail every 30 seconds.
+func (s *Server) deliverMail() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		pending, err := s.repo.PendingMail(50)
+		if err != nil {
+			log.Printf("reading outbox failed: %s\n", err)
+		}
+		for _, mail := range pending {
+			err := s.mailer.Send(mailer.Message{To: mail.Recipient, Subject: mail.Subject, Body: mail.Body})
+			if err != nil {
+				log.Printf("sending mail %d failed: %s\n", mail.MailID, err)
+				s.repo.MarkMailFailed(mail.MailID, err)
+				continue
+			}
+			s.repo.MarkMailSent(mail.MailID)
+		}
+		<-ticker.C
+	}
+}
+
+// inviteUser issues an activation token for the user and queues the mail with
+// the link, valid for INVITATION_TTL (72h by default), to set its password.
+// The mail is written in the language the user chose, or else in lang.
+func (s *Server) inviteUser(user *services.User, lang string) error {
+	ttl, err := time.ParseDuration(getEnvVar("INVITATION_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = 72 * time.Hour
+	}
+	token, err := s.repo.CreateInvitation(user.UserID, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/activate?token=%s", strings.TrimSuffix(getEnvVar("APP_URL"), "/"), token)
+	if i18n.Supported(user.Lang) {
+		lang = user.Lang
+	}
+	body := i18n.T(lang, "invitation_body", user.Name, link, time.Now().Add(ttl).Format("02/01/2006 15:04"))
+	return s.repo.QueueMail(user.Email, i18n.T(lang, "invitation_subject"), body)
+}
+
+func (s *Server) sendInvitation(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		// Retrieve id
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		user, err := repo.GetUserById(intid)
+		if err != nil {
+			writeMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if !user.Active {
+			writeMessage(w, http.StatusBadRequest, "inactive")
+			return
+		}
+
+		if err := s.inviteUser(user, w.Header().Get("Content-Language")); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		res := struct {
+			Message string `json:"message"`
+		}{localize(w, "invitation_sent")}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (s *Server) activateUser(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Retrieve json
+		var activation services.Activation
+		if err := decodeJSON(w, r, &activation); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		user, err := repo.ActivateUser(activation)
+		if errors.Is(err, services.ErrInvalidToken) {
+			writeMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response token, the user is logged in right away
+		res := struct {
+			Token string `json:"access_token"`
+		}{generarToken(user.UserID, user.Email, user.Role.Name, time.Now())}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
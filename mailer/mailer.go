@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers a message. Messages are not sent directly by the handlers,
+// they are queued in the outbox and delivered by a background job.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// LogMailer only logs the messages, it is used when no SMTP server is set.
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Message) error {
+	log.Printf("mail to %s: %s\n%s\n", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+type SMTPMailer struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+func (m SMTPMailer) Send(msg Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Body)
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{msg.To}, []byte(b.String()))
+}
+
+// New returns an SMTPMailer for addr (host:port), authenticating when user is
+// set, or a LogMailer when addr is empty.
+func New(addr string, from string, user string, password string) Mailer {
+	if addr == "" {
+		return LogMailer{}
+	}
+	var auth smtp.Auth
+	if user != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+	return SMTPMailer{Addr: addr, From: from, Auth: auth}
+}
@@ -1,12 +1,20 @@
-package main
+package pentview
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go-pentview/blobs"
+	"go-pentview/exports"
+	"go-pentview/i18n"
+	"go-pentview/images"
+	"go-pentview/mailer"
 	"go-pentview/services"
+	"go-pentview/validate"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -20,37 +28,42 @@ import (
 	"github.com/joho/godotenv"
 )
 
+// getEnvVar reads a variable of the environment, loading first the .env file
+// when there is one.
 func getEnvVar(key string) string {
 	err := godotenv.Load(".env")
 
-	if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		log.Fatal(err)
 	}
 	return os.Getenv(key)
 }
 
-func main() {
+// ListenAndServe serves the API on PORT to the CORS origin.
+func ListenAndServe() error {
 	// Where ORIGIN_ALLOWED is like `scheme://dns[:port]`, or `*` (insecure)
 	headersOk := handlers.AllowedHeaders([]string{"X-Requested-With", "Authorization", "Accept", "Accept-Language", "Content-Type", "Content-Language", "Content-Disposition", "Origin"})
 	originsOk := handlers.AllowedOrigins([]string{getEnvVar("CORS")})
 	methodsOk := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE"})
 
-	srv := initServer()
-	err := http.ListenAndServe(":"+getEnvVar("PORT"), handlers.CORS(originsOk, headersOk, methodsOk)(srv.Router))
-	if errors.Is(err, http.ErrServerClosed) {
-		fmt.Printf("server closed\n")
-	} else if err != nil {
-		fmt.Printf("error starting server: %s\n", err)
-		os.Exit(1)
-	}
+	srv := NewServer()
+	return http.ListenAndServe(":"+getEnvVar("PORT"), handlers.CORS(originsOk, headersOk, methodsOk)(srv))
 }
 
 type Server struct {
 	*mux.Router
-	repo *services.SQLiteRepository
+	repo   *services.SQLiteRepository
+	mailer mailer.Mailer
+	images *images.Store
+	// privateImages makes uploaded images only reachable through signed URLs
+	privateImages bool
+	// imageSecret signs the URLs of images served by the server
+	imageSecret string
 }
 
-func initServer() *Server {
+// NewServer recreates the database at DBPATH and returns the server with its
+// routes, starting the background jobs.
+func NewServer() *Server {
 	os.Remove(getEnvVar("DBPATH"))
 	db, err := sql.Open("sqlite3", getEnvVar("DBPATH"))
 	if err != nil {
@@ -60,13 +73,32 @@ func initServer() *Server {
 	if err := repo.Migrate(); err != nil {
 		log.Fatal(err)
 	}
+	if err := exports.LoadTemplates(getEnvVar("PAYROLL_TEMPLATES")); err != nil {
+		log.Fatal(err)
+	}
 
 	s := &Server{
 		Router: mux.NewRouter(),
 		repo:   repo,
+		mailer: mailer.New(getEnvVar("SMTP_ADDR"), getEnvVar("SMTP_FROM"), getEnvVar("SMTP_USER"), getEnvVar("SMTP_PASSWORD")),
+		images: images.NewStore(blobs.New("data/img", getEnvVar("IMAGE_SECRET"), blobs.S3Config{
+			Endpoint:  getEnvVar("S3_ENDPOINT"),
+			Region:    getEnvVar("S3_REGION"),
+			Bucket:    getEnvVar("S3_BUCKET"),
+			AccessKey: getEnvVar("S3_ACCESS_KEY"),
+			SecretKey: getEnvVar("S3_SECRET_KEY"),
+		})),
+		privateImages: getEnvVar("PRIVATE_IMAGES") == "true",
+		imageSecret:   getEnvVar("IMAGE_SECRET"),
+	}
+	if s.privateImages && s.imageSecret == "" {
+		log.Fatal("IMAGE_SECRET is required with PRIVATE_IMAGES")
 	}
 	s.createAdminUser()
 	s.routes()
+	go s.accrueBalances()
+	go s.closeForgottenClockings()
+	go s.deliverMail()
 	return s
 }
 
@@ -82,34 +114,102 @@ func (s *Server) createAdminUser() {
 }
 
 func (s *Server) routes() {
+	s.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeMessage(w, http.StatusNotFound, "route_not_found")
+	})
+	s.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeMessage(w, http.StatusMethodNotAllowed, "method_not_allowed")
+	})
+	doc := apiDocument()
+	s.HandleFunc("/openapi.json", s.getOpenAPI(doc)).Methods("GET")
+	s.HandleFunc("/docs", s.getDocs()).Methods("GET")
+	s.HandleFunc("/upload/avatar/{id:[0-9]+}.{format:svg|png}", s.getAvatar(s.repo)).Methods("GET")
 	s.HandleFunc("/upload/{img}", s.getPFP()).Methods("GET")
 	s.HandleFunc("/employee-service/user/auth/login", s.login(s.repo)).Methods("POST")
+	s.HandleFunc("/employee-service/user/auth/activate", s.activateUser(s.repo)).Methods("POST")
+	s.HandleFunc("/employee-service/user/auth/refresh", s.refreshToken(s.repo)).Methods("POST")
 	s.HandleFunc("/employee-service/user/profile", s.getProfile(s.repo)).Methods("GET")
 	s.HandleFunc("/employee-service/user/update-profile", s.updateProfile(s.repo)).Methods("PUT")
+	s.HandleFunc("/employee-service/user/profile-image", s.putProfileImage(s.repo)).Methods("PUT")
+	s.HandleFunc("/employee-service/user/profile-image", s.deleteProfileImage(s.repo)).Methods("DELETE")
+	s.HandleFunc("/employee-service/user/balance", s.getBalance(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/user/personal-data", s.getPersonalData(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/user/hour-balance", s.getHourBalance(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/user/notifications", s.getNotifications(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/user/notifications/{id}", s.readNotification(s.repo)).Methods("PUT")
 	s.HandleFunc("/employee-service/role", s.createRole(s.repo)).Methods("POST")
 	s.HandleFunc("/employee-service/role", s.getRoles(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/role/{id}", s.updateRole(s.repo)).Methods("PUT")
+	s.HandleFunc("/employee-service/role/{id}", s.deleteRole(s.repo)).Methods("DELETE")
 	s.HandleFunc("/employee-service/user", s.createUser(s.repo)).Methods("POST")
 	s.HandleFunc("/employee-service/user/list", s.getUsers(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/user/import", s.importUsers(s.repo)).Methods("POST")
+	s.HandleFunc("/employee-service/user/reports", s.getReports(s.repo)).Methods("GET")
 	s.HandleFunc("/employee-service/user/{id}", s.updateUser(s.repo)).Methods("PUT")
 	s.HandleFunc("/employee-service/user/{id}", s.deleteUser(s.repo)).Methods("DELETE")
+	s.HandleFunc("/employee-service/user/{id}/reactivate", s.reactivateUser(s.repo)).Methods("PUT")
+	s.HandleFunc("/employee-service/user/{id}/invitation", s.sendInvitation(s.repo)).Methods("POST")
+	s.HandleFunc("/employee-service/user/{id}/profile-image", s.getProfileImageURL(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/user/{id}/personal-data", s.getUserPersonalData(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/user/{id}/anonymize", s.anonymizeUser(s.repo)).Methods("PUT")
+	s.HandleFunc("/employee-service/user/{id}/purge", s.purgeUser(s.repo)).Methods("DELETE")
+	s.HandleFunc("/employee-service/user/{id}/contract", s.createContract(s.repo)).Methods("POST")
+	s.HandleFunc("/employee-service/user/{id}/contract", s.getContracts(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/user/{id}/hour-balance", s.getUserHourBalance(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/user/{id}/fields", s.setUserFields(s.repo)).Methods("PUT")
+	s.HandleFunc("/employee-service/user/{id}/hierarchy", s.setHierarchy(s.repo)).Methods("PUT")
+	s.HandleFunc("/employee-service/user/{id}/balance", s.getUserBalance(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/user/{id}/balance/adjustment", s.adjustBalance(s.repo)).Methods("POST")
+	s.HandleFunc("/employee-service/entitlement", s.setEntitlement(s.repo)).Methods("POST")
+	s.HandleFunc("/employee-service/entitlement", s.getEntitlements(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/entitlement/{id}", s.deleteEntitlement(s.repo)).Methods("DELETE")
 	s.HandleFunc("/employee-service/hour-register", s.createClocking(s.repo)).Methods("POST")
 	s.HandleFunc("/employee-service/hour-register", s.getClockings(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/hour-register/reports", s.getReportClockings(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/hour-register/review", s.getClockingsToReview(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/hour-register/{id}/review", s.reviewClocking(s.repo)).Methods("PUT")
+	s.HandleFunc("/employee-service/export/hour-register", s.exportClockings(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/export/timesheet", s.exportTimesheets(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/export/payroll", s.exportPayroll(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/contract/{id}", s.deleteContract(s.repo)).Methods("DELETE")
+	s.HandleFunc("/employee-service/field", s.createField(s.repo)).Methods("POST")
+	s.HandleFunc("/employee-service/field", s.getFields(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/field/{id}", s.deleteField(s.repo)).Methods("DELETE")
+	s.HandleFunc("/employee-service/department", s.createDepartment(s.repo)).Methods("POST")
+	s.HandleFunc("/employee-service/department", s.getDepartments(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/department/{id}", s.deleteDepartment(s.repo)).Methods("DELETE")
+	s.HandleFunc("/employee-service/team", s.createTeam(s.repo)).Methods("POST")
+	s.HandleFunc("/employee-service/team", s.getTeams(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/team/{id}", s.deleteTeam(s.repo)).Methods("DELETE")
+	s.HandleFunc("/employee-service/pay-period", s.createPayPeriod(s.repo)).Methods("POST")
+	s.HandleFunc("/employee-service/pay-period", s.getPayPeriods(s.repo)).Methods("GET")
+	s.HandleFunc("/employee-service/pay-period/{id}/close", s.closePayPeriod(s.repo)).Methods("PUT")
+	s.HandleFunc("/employee-service/pay-period/{id}/reopen", s.reopenPayPeriod(s.repo)).Methods("PUT")
 }
 
-func generarToken(user_id int64, username string, role string) string {
+// maxSession is how long tokens can be refreshed after logging in, past it
+// users have to log in again.
+const maxSession = 12 * time.Hour
+
+// generarToken issues a token for an hour. authTime is when the user logged
+// in, kept as is by refreshes to bound the session.
+func generarToken(user_id int64, username string, role string, authTime time.Time) string {
 	key := []byte(getEnvVar("TOKEN"))
 	t := jwt.NewWithClaims(jwt.SigningMethodHS256,
 		jwt.MapClaims{
 			"username":  username,
 			"sub":       user_id,
 			"authority": role,
+			"auth_time": authTime.Unix(),
 			"iat":       time.Now().Unix(),
 			"exp":       time.Now().Add(time.Hour).Unix(),
 		})
 	s, _ := t.SignedString(key)
 	return s
 }
-func validateToken(token string) (bool, int64) {
+
+// parseToken returns the claims of a valid token.
+func parseToken(token string) (jwt.MapClaims, bool) {
 	tokenDecoded, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("failed to parse")
@@ -117,46 +217,373 @@ func validateToken(token string) (bool, int64) {
 		return []byte(getEnvVar("TOKEN")), nil
 	})
 	if err != nil {
+		return nil, false
+	}
+	claims, ok := tokenDecoded.Claims.(jwt.MapClaims)
+	return claims, ok && tokenDecoded.Valid
+}
+
+func validateToken(token string) (bool, int64) {
+	claims, isValid := parseToken(token)
+	if !isValid {
 		return false, 0
 	}
-	if claims, ok := tokenDecoded.Claims.(jwt.MapClaims); ok && tokenDecoded.Valid {
-		sub := fmt.Sprint(claims["sub"])
-		if len(sub) > 0 {
-			id, err := strconv.ParseInt(sub, 10, 64)
-			if err != nil {
-				panic(err)
-			}
-			return tokenDecoded.Valid, id
+	sub := fmt.Sprint(claims["sub"])
+	if len(sub) > 0 {
+		id, err := strconv.ParseInt(sub, 10, 64)
+		if err != nil {
+			return false, 0
+		}
+		return true, id
+	}
+	return true, 0
+}
+
+// bearerToken returns the token of the Authorization header, "" without one.
+func bearerToken(r *http.Request) string {
+	auth := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(auth) < 2 {
+		return ""
+	}
+	return auth[1]
+}
+
+// authTime returns when the user of the token of the request logged in, from
+// its auth_time claim or its iat for tokens issued without one.
+func authTime(r *http.Request) (time.Time, bool) {
+	claims, isValid := parseToken(bearerToken(r))
+	if !isValid {
+		return time.Time{}, false
+	}
+	for _, claim := range []string{"auth_time", "iat"} {
+		if at, ok := claims[claim].(float64); ok {
+			return time.Unix(int64(at), 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// session is the user behind the token of a request, resolved once by
+// ServeHTTP and carried in the context of the request under sessionKey.
+type session struct {
+	valid  bool
+	userID int64
+	lang   string
+}
+
+type sessionKey struct{}
+
+// resolveSession validates the Bearer token of the request and reads whether
+// its user is still active, and its language, with a single query.
+func (s *Server) resolveSession(r *http.Request) session {
+	token := bearerToken(r)
+	if token == "" {
+		return session{}
+	}
+	isValid, user_id := validateToken(token)
+	if !isValid {
+		return session{}
+	}
+	active, lang, err := s.repo.GetSession(user_id)
+	if err != nil || !active {
+		return session{}
+	}
+	return session{valid: true, userID: user_id, lang: lang}
+}
+
+// authenticate returns whether the request carries a valid Bearer token and
+// the id of its user, which must still be active: the tokens of deactivated
+// users stop working before they expire.
+func (s *Server) authenticate(r *http.Request) (bool, int64) {
+	current, ok := r.Context().Value(sessionKey{}).(session)
+	if !ok {
+		current = s.resolveSession(r)
+	}
+	return current.valid, current.userID
+}
+
+func isAdmin(repo *services.SQLiteRepository, user_id int64) bool {
+	role, active, err := repo.GetUserRole(user_id)
+	return err == nil && active && role == services.AdminRole
+}
+
+// isManager tells whether the active user has someone reporting to it.
+func isManager(repo *services.SQLiteRepository, user_id int64) bool {
+	active, err := repo.IsActive(user_id)
+	if err != nil || !active {
+		return false
+	}
+	hasReports, err := repo.HasReports(user_id)
+	return err == nil && hasReports
+}
+
+// canManage tells whether the user may act on behalf of an admin over the
+// records of another user: admins manage everyone, managers their reports.
+func canManage(repo *services.SQLiteRepository, user_id int64, report_id int64) bool {
+	if isAdmin(repo, user_id) {
+		return true
+	}
+	active, err := repo.IsActive(user_id)
+	if err != nil || !active {
+		return false
+	}
+	isReport, err := repo.IsManagerOf(user_id, report_id)
+	return err == nil && isReport
+}
+
+// writeMessage responds message with status, translated when it is a key of
+// the catalog. Error statuses are responded as an APIError, with the key as
+// code or else the code of the status.
+func writeMessage(w http.ResponseWriter, status int, message string) {
+	if status >= http.StatusBadRequest {
+		code := message
+		if !i18n.Has(code) {
+			code = statusCode(status)
 		}
+		writeError(w, &APIError{Status: status, Code: code, Message: message})
+		return
+	}
+	w.WriteHeader(status)
+	msg := struct {
+		Message string `json:"message"`
+	}{Message: localize(w, message)}
+	json.NewEncoder(w).Encode(msg)
+}
+
+// localize translates the catalog key to the language of the response.
+func localize(w http.ResponseWriter, key string) string {
+	return i18n.T(w.Header().Get("Content-Language"), key)
+}
+
+// maxBodySize bounds the JSON bodies of requests.
+const maxBodySize = 1 << 20
+
+// decodeJSON decodes the body of r into dst and validates it. The body must be
+// a single JSON value of at most maxBodySize bytes with no unknown fields.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	return decodeStrict(r.Body, dst)
+}
+
+// decodeStrict decodes the only JSON value of body into dst, rejecting unknown
+// fields, and validates it.
+func decodeStrict(body io.Reader, dst any) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return err
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return &APIError{Status: http.StatusBadRequest, Code: "invalid_json", Message: "body must be a single JSON value"}
 	}
-	return tokenDecoded.Valid, 0
+	return validate.Struct(dst)
 }
 
-func UploadPFP(r *http.Request) {
-	file, handler, err := r.FormFile("image")
+// uploadPFP stores the "image" file of a multipart request and returns the
+// path it is served from, or the default image when the request has none.
+// Unless it fails, the image is reserved against removePFP until the caller
+// calls release, once a user references it.
+func (s *Server) uploadPFP(w http.ResponseWriter, r *http.Request) (pfp string, release func(), err error) {
+	r.Body = http.MaxBytesReader(w, r.Body, images.MaxSize+1<<20)
+	file, _, err := r.FormFile("image")
+	if errors.Is(err, http.ErrMissingFile) {
+		return services.DefaultPFP, func() {}, nil
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return "", nil, images.ErrTooLarge
+	}
 	if err != nil {
-		panic(err)
+		return "", nil, badRequest("invalid_form", err)
 	}
 	defer file.Close()
 
-	f, err := os.OpenFile("data/img/"+handler.Filename, os.O_WRONLY|os.O_CREATE, 0666)
+	upload, err := s.images.Prepare(file)
 	if err != nil {
-		panic(err)
+		return "", nil, err
+	}
+	pfp = "upload/" + upload.Name
+
+	// Wait for a removal of the same image to delete its blobs first
+	var claim int64
+	for attempt := 0; ; attempt++ {
+		claim, err = s.repo.ReservePFP(pfp)
+		if !errors.Is(err, services.ErrPFPRemoving) || attempt == 20 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	release = func() {
+		if err := s.repo.ReleasePFP(claim); err != nil {
+			log.Printf("releasing image %s failed: %s\n", pfp, err)
+		}
+	}
+
+	if err := s.images.Put(upload); err != nil {
+		release()
+		return "", nil, err
+	}
+	return pfp, release, nil
+}
+
+// pfpName returns the stored image behind a profile image path, or "" for the
+// default image and generated avatars.
+func pfpName(pfp string) string {
+	if pfp == services.DefaultPFP || strings.HasPrefix(pfp, "upload/avatar/") {
+		return ""
+	}
+	return strings.TrimPrefix(pfp, "upload/")
+}
+
+// avatarName returns the name the avatar of a user is signed with, its path
+// under upload/.
+func avatarName(id int64, format string) string {
+	return fmt.Sprintf("avatar/%d.%s", id, format)
+}
+
+// signAvatar returns the profile image path of a generated avatar carrying a
+// signature until expiry, like the uploads of blobs.Local.
+func (s *Server) signAvatar(pfp string, expiry time.Duration) string {
+	name := strings.TrimPrefix(pfp, "upload/")
+	expires := time.Now().Add(expiry).Unix()
+	return fmt.Sprintf("%s?expires=%d&signature=%s", pfp, expires, blobs.Sign(s.imageSecret, name, expires))
+}
+
+// removePFP deletes the stored profile image once no user has it anymore.
+func (s *Server) removePFP(pfp string) {
+	name := pfpName(pfp)
+	if name == "" {
+		return
+	}
+	claim, ok, err := s.repo.ClaimPFPRemoval(pfp)
+	if err != nil || !ok {
+		return
+	}
+	if err := s.images.Remove(name); err != nil {
+		log.Printf("removing image %s failed: %s\n", name, err)
+	}
+	if err := s.repo.ReleasePFP(claim); err != nil {
+		log.Printf("releasing image %s failed: %s\n", name, err)
 	}
-	defer f.Close()
-	_, _ = io.Copy(f, file)
-	fmt.Printf("File %s saved!\n", handler.Filename)
 }
+
+// getPFP serves a profile image, or its square variant of ?size= pixels.
 func (s *Server) getPFP() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		imgpath := mux.Vars(r)["img"]
-		img, err := os.Open("data/img/" + imgpath)
+		var size int
+		if param := r.URL.Query().Get("size"); param != "" {
+			var err error
+			if size, err = strconv.Atoi(param); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				writeError(w, images.ErrInvalidSize)
+				return
+			}
+		}
+
+		name := mux.Vars(r)["img"]
+		if s.privateImages {
+			query := r.URL.Query()
+			if err := blobs.Verify(s.imageSecret, name, query.Get("expires"), query.Get("signature")); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				writeError(w, err)
+				return
+			}
+		}
+
+		img, err := s.images.Open(name, size)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case errors.Is(err, images.ErrInvalidName), errors.Is(err, images.ErrInvalidSize):
+				writeError(w, err)
+			case errors.Is(err, images.ErrNotFound):
+				writeMessage(w, http.StatusNotFound, err.Error())
+			default:
+				log.Printf("opening image failed: %s\n", err)
+				writeMessage(w, http.StatusInternalServerError, "image_read_failed")
+			}
+			return
+		}
+
+		cacheability := "public"
+		if s.privateImages {
+			cacheability = "private"
+		}
+		w.Header().Set("Content-Type", img.ContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if img.Immutable {
+			w.Header().Set("ETag", `"`+img.Name+`"`)
+			w.Header().Set("Cache-Control", cacheability+", max-age=31536000, immutable")
+		} else {
+			// Legacy names may be overwritten, so they are revalidated
+			w.Header().Set("ETag", fmt.Sprintf(`"%s-%x"`, img.Name, img.ModTime.UnixNano()))
+			w.Header().Set("Cache-Control", cacheability+", no-cache")
+		}
+		http.ServeContent(w, r, img.Name, img.ModTime, img.Reader)
+	}
+}
+
+// getAvatar serves the generated avatar of an active user, as SVG or PNG, with
+// the size picked by ?size= like getPFP. With private images it needs a
+// signature like the uploads.
+func (s *Server) getAvatar(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		size := images.Sizes[len(images.Sizes)-1]
+		if param := r.URL.Query().Get("size"); param != "" {
+			var err error
+			if size, err = strconv.Atoi(param); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				writeError(w, images.ErrInvalidSize)
+				return
+			}
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, err)
+			return
+		}
+		format := mux.Vars(r)["format"]
+		if s.privateImages {
+			query := r.URL.Query()
+			if err := blobs.Verify(s.imageSecret, avatarName(intid, format), query.Get("expires"), query.Get("signature")); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				writeError(w, err)
+				return
+			}
+		}
+
+		user, err := repo.GetUserById(intid)
+		if err != nil || !user.Active {
+			w.Header().Set("Content-Type", "application/json")
+			writeMessage(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		avatar := images.NewAvatar(user.UserID, user.Name, user.Last)
+		b, err := s.images.Avatar(avatar, size, format)
 		if err != nil {
-			log.Fatal(err) // perhaps handle this nicer
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, err)
+			return
 		}
-		defer img.Close()
-		w.Header().Set("Content-Type", "image/png") // <-- set the content-type header
-		io.Copy(w, img)
+
+		contentType := "image/png"
+		if format == "svg" {
+			contentType = "image/svg+xml"
+			// The markup is ours, but it must not run anything if opened directly
+			w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		// Names change, so the avatar is revalidated against its ETag
+		w.Header().Set("ETag", avatar.ETag(size, format))
+		w.Header().Set("Cache-Control", "public, no-cache")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
 	}
 }
 
@@ -166,21 +593,20 @@ func (s *Server) login(repo *services.SQLiteRepository) http.HandlerFunc {
 
 		// Retrieve json
 		var credentials services.Credentials
-		json.NewDecoder(r.Body).Decode(&credentials)
+		if err := decodeJSON(w, r, &credentials); err != nil {
+			writeError(w, err)
+			return
+		}
 
 		user, err := repo.CompareCredentials(credentials)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: err.Error()}
-			json.NewEncoder(w).Encode(msg)
+			writeError(w, err)
 			return
 		}
 
 		res := struct {
 			Token string `json:"access_token"`
-		}{generarToken(user.UserID, user.Email, user.Role.Name)}
+		}{generarToken(user.UserID, user.Email, user.Role.Name, time.Now())}
 		if err := json.NewEncoder(w).Encode(res); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -188,34 +614,65 @@ func (s *Server) login(repo *services.SQLiteRepository) http.HandlerFunc {
 	}
 }
 
-func (s *Server) getProfile(repo *services.SQLiteRepository) http.HandlerFunc {
+// refreshToken issues a new token for the user of a valid one, so sessions
+// outlive the hour a token lasts while the user stays active, up to
+// maxSession since logging in.
+func (s *Server) refreshToken(repo *services.SQLiteRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
 		// Auth
-		auth := r.Header.Get("Authorization")
-		var (
-			token   string
-			isValid bool
-			user_id int64
-		)
-		if len(auth) > 0 {
-			token = strings.Split(auth, " ")[1]
-			isValid, user_id = validateToken(token)
-		} else {
-			isValid = false
+		isValid, user_id := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		loggedIn, ok := authTime(r)
+		if !ok || time.Since(loggedIn) > maxSession {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		user, err := repo.GetProfileById(user_id)
+		if errors.Is(err, services.ErrNotExists) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !user.Active {
+			writeError(w, services.ErrInactive)
+			return
+		}
+
+		res := struct {
+			Token string `json:"access_token"`
+		}{generarToken(user.UserID, user.Email, user.Role.Name, loggedIn)}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+	}
+}
+
+func (s *Server) getProfile(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
 
+		// Auth
+		isValid, user_id := s.authenticate(r)
 		if !isValid {
-			w.WriteHeader(http.StatusUnauthorized)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: "no autorizado"}
-			json.NewEncoder(w).Encode(msg)
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 
-		profile, _ := repo.GetProfileById(user_id)
+		profile, err := repo.GetProfileById(user_id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
 		if err := json.NewEncoder(w).Encode(profile); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -227,52 +684,34 @@ func (s *Server) updateProfile(repo *services.SQLiteRepository) http.HandlerFunc
 		w.Header().Set("Content-Type", "application/json")
 
 		// Auth
-		auth := r.Header.Get("Authorization")
-		var (
-			token   string
-			isValid bool
-			user_id int64
-		)
-		if len(auth) > 0 {
-			token = strings.Split(auth, " ")[1]
-			isValid, user_id = validateToken(token)
-		} else {
-			isValid = false
-		}
-
+		isValid, user_id := s.authenticate(r)
 		if !isValid {
-			w.WriteHeader(http.StatusUnauthorized)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: "no autorizado"}
-			json.NewEncoder(w).Encode(msg)
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 
 		// Retrieve body
 		var body services.PutProfile
-		err := json.NewDecoder(r.Body).Decode(&body)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: err.Error()}
-			json.NewEncoder(w).Encode(msg)
+		if err := decodeJSON(w, r, &body); err != nil {
+			writeError(w, err)
 			return
 		}
 
 		// Update profile
-		profile, _ := repo.GetProfileById(user_id)
+		profile, err := repo.GetProfileById(user_id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
 		profile.Name = body.Name
 		profile.Last = body.Last
 		profile.Email = body.Email
+		if body.Lang != nil {
+			profile.Lang = *body.Lang
+		}
 		_, err = repo.UpdateProfile(user_id, *profile)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: err.Error()}
-			json.NewEncoder(w).Encode(msg)
+			writeError(w, err)
 			return
 		}
 
@@ -280,7 +719,7 @@ func (s *Server) updateProfile(repo *services.SQLiteRepository) http.HandlerFunc
 		res := struct {
 			Message string        `json:"message"`
 			User    services.User `json:"user"`
-		}{"Usuario actualizado correctamente", *profile}
+		}{localize(w, "user_updated"), *profile}
 
 		if err := json.NewEncoder(w).Encode(res); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -294,39 +733,23 @@ func (s *Server) createRole(repo *services.SQLiteRepository) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 
 		// Auth
-		auth := r.Header.Get("Authorization")
-		var (
-			token   string
-			isValid bool
-		)
-		if len(auth) > 0 {
-			token = strings.Split(auth, " ")[1]
-			isValid, _ = validateToken(token)
-		} else {
-			isValid = false
-		}
-
-		if !isValid {
-			w.WriteHeader(http.StatusUnauthorized)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: "no autorizado"}
-			json.NewEncoder(w).Encode(msg)
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 
 		// Retrieve json
 		var role services.Role
-		json.NewDecoder(r.Body).Decode(&role)
+		if err := decodeJSON(w, r, &role); err != nil {
+			writeError(w, err)
+			return
+		}
 
 		// Create role
 		roleCreated, err := repo.CreateRole(role)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: err.Error()}
-			json.NewEncoder(w).Encode(msg)
+			writeError(w, err)
 			return
 		}
 
@@ -334,7 +757,7 @@ func (s *Server) createRole(repo *services.SQLiteRepository) http.HandlerFunc {
 		res := struct {
 			Message string        `json:"message"`
 			Role    services.Role `json:"role"`
-		}{"Rol creado correctamente", *roleCreated}
+		}{localize(w, "role_created"), *roleCreated}
 
 		if err := json.NewEncoder(w).Encode(res); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -347,30 +770,18 @@ func (s *Server) getRoles(repo *services.SQLiteRepository) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 
 		// Auth
-		auth := r.Header.Get("Authorization")
-		var (
-			token   string
-			isValid bool
-		)
-		if len(auth) > 0 {
-			token = strings.Split(auth, " ")[1]
-			isValid, _ = validateToken(token)
-		} else {
-			isValid = false
-		}
-
+		isValid, _ := s.authenticate(r)
 		if !isValid {
-			w.WriteHeader(http.StatusUnauthorized)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: "no autorizado"}
-			json.NewEncoder(w).Encode(msg)
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 
-		roles, _ := repo.AllRoles()
+		roles, _ := repo.AllRolesWithUsage()
+		if len(roles) == 0 {
+			roles = []services.RoleUsage{}
+		}
 		data := struct {
-			Data []services.Role `json:"data"`
+			Data []services.RoleUsage `json:"data"`
 		}{Data: roles}
 		if err := json.NewEncoder(w).Encode(data); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -378,65 +789,149 @@ func (s *Server) getRoles(repo *services.SQLiteRepository) http.HandlerFunc {
 		}
 	}
 }
+func (s *Server) updateRole(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
 
-func (s *Server) createUser(repo *services.SQLiteRepository) http.HandlerFunc {
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Retrieve json
+		var role services.Role
+		if err := decodeJSON(w, r, &role); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Update role
+		roleUpdated, err := repo.UpdateRole(intid, role)
+		if errors.Is(err, services.ErrNotExists) {
+			writeMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response role
+		res := struct {
+			Message string        `json:"message"`
+			Role    services.Role `json:"role"`
+		}{localize(w, "role_updated"), *roleUpdated}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// deleteRole deletes a role, moving its users to the role given by the
+// reassign query parameter when it still has any.
+func (s *Server) deleteRole(repo *services.SQLiteRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
 		// Auth
-		auth := r.Header.Get("Authorization")
-		var (
-			token   string
-			isValid bool
-		)
-		if len(auth) > 0 {
-			token = strings.Split(auth, " ")[1]
-			isValid, _ = validateToken(token)
-		} else {
-			isValid = false
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
 		}
 
-		if !isValid {
-			w.WriteHeader(http.StatusUnauthorized)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: "no autorizado"}
-			json.NewEncoder(w).Encode(msg)
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		var reassign int64
+		if value := r.URL.Query().Get("reassign"); value != "" {
+			if reassign, err = strconv.ParseInt(value, 10, 64); err != nil {
+				writeMessage(w, http.StatusBadRequest, "invalid_reassign")
+				return
+			}
+		}
+
+		err = repo.DeleteRole(intid, reassign)
+		switch {
+		case errors.Is(err, services.ErrNotExists):
+			writeMessage(w, http.StatusNotFound, err.Error())
+			return
+		case errors.Is(err, services.ErrRoleInUse):
+			writeMessage(w, http.StatusConflict, "role_in_use")
+			return
+		case err != nil:
+			writeError(w, err)
+			return
+		}
+
+		writeMessage(w, http.StatusOK, "role_deleted")
+	}
+}
+
+func (s *Server) createUser(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 
 		// Retrieve files
-		UploadPFP(r)
+		pfp, release, err := s.uploadPFP(w, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
 		body := r.FormValue("json")
 
 		// Parse json
 		var userToCreate services.UserToCreate
-		err := json.Unmarshal([]byte(body), &userToCreate)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: err.Error()}
-			json.NewEncoder(w).Encode(msg)
+		if err := decodeStrict(strings.NewReader(body), &userToCreate); err != nil {
+			release()
+			s.removePFP(pfp)
+			writeError(w, err)
 			return
 		}
-		userToCreate.PFP = "upload/" + userToCreate.PFP
+		userToCreate.PFP = pfp
 
 		// Create user
-		err = repo.CreateUser(userToCreate)
+		user, err := repo.CreateUser(userToCreate)
+		release()
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: err.Error()}
-			json.NewEncoder(w).Encode(msg)
+			s.removePFP(pfp)
+			writeError(w, err)
 			return
 		}
 
+		// Invite user to choose its own password
+		message := "user_created"
+		if userToCreate.Password == "" {
+			if err := s.inviteUser(user, w.Header().Get("Content-Language")); err != nil {
+				writeError(w, err)
+				return
+			}
+			message = "user_created_invited"
+		}
+
 		// Response user
 		res := struct {
 			Message string `json:"message"`
-		}{"Usuario creado"}
+		}{localize(w, message)}
 		if err := json.NewEncoder(w).Encode(res); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -448,60 +943,89 @@ func (s *Server) getUsers(repo *services.SQLiteRepository) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 
 		// Auth
-		auth := r.Header.Get("Authorization")
-		var (
-			token   string
-			isValid bool
-		)
-		if len(auth) > 0 {
-			token = strings.Split(auth, " ")[1]
-			isValid, _ = validateToken(token)
-		} else {
-			isValid = false
+		isValid, _ := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
 		}
 
-		if !isValid {
-			w.WriteHeader(http.StatusUnauthorized)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: "no autorizado"}
-			json.NewEncoder(w).Encode(msg)
+		// Retrieve query
+		query, err := parseUserQuery(r)
+		if err != nil {
+			writeError(w, err)
 			return
 		}
 
-		users, _ := repo.AllUsers()
-		if len(users) == 0 {
-			users = []services.User{}
+		page, err := repo.SearchUsers(*query)
+		if err != nil {
+			writeError(w, err)
+			return
 		}
-		if err := json.NewEncoder(w).Encode(users); err != nil {
+		if err := json.NewEncoder(w).Encode(page); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 	}
 }
+
+// parseUserQuery reads page (default 1), limit (default 20), cursor, search,
+// role, status, sort, order (asc or desc) and field.<key> filters from the
+// query string.
+func parseUserQuery(r *http.Request) (*services.UserQuery, error) {
+	values := r.URL.Query()
+	query := services.UserQuery{
+		Page:   1,
+		Limit:  20,
+		Search: values.Get("search"),
+		Status: values.Get("status"),
+		Sort:   values.Get("sort"),
+	}
+
+	ints := []struct {
+		key string
+		set func(int64)
+	}{
+		{"page", func(v int64) { query.Page = int(v) }},
+		{"limit", func(v int64) { query.Limit = int(v) }},
+		{"cursor", func(v int64) { query.Cursor = v }},
+		{"role", func(v int64) { query.RoleID = v }},
+	}
+	for _, param := range ints {
+		if value := values.Get(param.key); value != "" {
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, badRequest("not_a_number", param.key)
+			}
+			param.set(v)
+		}
+	}
+
+	for key := range values {
+		if field, ok := strings.CutPrefix(key, "field."); ok {
+			if query.Fields == nil {
+				query.Fields = map[string]string{}
+			}
+			query.Fields[field] = values.Get(key)
+		}
+	}
+
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return nil, badRequest("invalid_order")
+	}
+	return &query, nil
+}
 func (s *Server) updateUser(repo *services.SQLiteRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
 		// Auth
-		auth := r.Header.Get("Authorization")
-		var (
-			token   string
-			isValid bool
-		)
-		if len(auth) > 0 {
-			token = strings.Split(auth, " ")[1]
-			isValid, _ = validateToken(token)
-		} else {
-			isValid = false
-		}
-
-		if !isValid {
-			w.WriteHeader(http.StatusUnauthorized)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: "no autorizado"}
-			json.NewEncoder(w).Encode(msg)
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 
@@ -509,40 +1033,42 @@ func (s *Server) updateUser(repo *services.SQLiteRepository) http.HandlerFunc {
 		id := mux.Vars(r)["id"]
 		intid, err := strconv.ParseInt(id, 10, 64)
 		if err != nil {
-			log.Fatal(err)
+			writeError(w, err)
+			return
 		}
 
-		// Retrieve body
-		var userToUpdate services.User
-		err = json.NewDecoder(r.Body).Decode(&userToUpdate)
+		// Retrieve body, where an absent employee code is kept and an empty one
+		// clears it
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: err.Error()}
-			json.NewEncoder(w).Encode(msg)
+			writeError(w, err)
+			return
+		}
+		var userToUpdate services.User
+		if err := decodeStrict(bytes.NewReader(body), &userToUpdate); err != nil {
+			writeError(w, err)
+			return
+		}
+		var present struct {
+			EmployeeCode *string `json:"employeeCode"`
+		}
+		if err := json.Unmarshal(body, &present); err != nil {
+			writeError(w, err)
 			return
 		}
 
-		// Create user
-		user, err := repo.UpdateUser(intid, userToUpdate)
+		// Update user
+		userUpdated, err := repo.UpdateUser(intid, userToUpdate, present.EmployeeCode == nil)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: err.Error()}
-			json.NewEncoder(w).Encode(msg)
+			writeError(w, err)
 			return
 		}
 
-		// Read user
-		userUpdated, _ := repo.GetUserById(user.UserID)
-
 		// Response user
 		res := struct {
 			Message string        `json:"message"`
 			User    services.User `json:"user"`
-		}{"Usuario actualizado correctamente", *userUpdated}
+		}{localize(w, "user_updated"), *userUpdated}
 
 		if err := json.NewEncoder(w).Encode(res); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -555,88 +1081,121 @@ func (s *Server) deleteUser(repo *services.SQLiteRepository) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 
 		// Auth
-		auth := r.Header.Get("Authorization")
-		var (
-			token   string
-			isValid bool
-		)
-		if len(auth) > 0 {
-			token = strings.Split(auth, " ")[1]
-			isValid, _ = validateToken(token)
-		} else {
-			isValid = false
-		}
-
-		if !isValid {
-			w.WriteHeader(http.StatusUnauthorized)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: "no autorizado"}
-			json.NewEncoder(w).Encode(msg)
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 
 		id := mux.Vars(r)["id"]
 		intid, err := strconv.ParseInt(id, 10, 64)
 		if err != nil {
-			log.Fatal(err)
+			writeError(w, err)
+			return
+		}
+		if intid == admin_id {
+			writeMessage(w, http.StatusBadRequest, "cannot_deactivate_self")
+			return
 		}
-		err = repo.DeleteUser(intid)
+		err = repo.DeactivateUser(intid)
 		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: err.Error()}
-			json.NewEncoder(w).Encode(msg)
+			writeError(w, err)
 			return
 		}
 
 		res := struct {
 			Message string `json:"message"`
-		}{"Delete user"}
+		}{localize(w, "user_deactivated")}
 		if err := json.NewEncoder(w).Encode(res); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 	}
 }
+func (s *Server) reactivateUser(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
 
-func (s *Server) createClocking(repo *services.SQLiteRepository) http.HandlerFunc {
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := repo.ReactivateUser(intid); err != nil {
+			writeMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		res := struct {
+			Message string `json:"message"`
+		}{localize(w, "user_reactivated")}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) purgeUser(repo *services.SQLiteRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
 		// Auth
-		auth := r.Header.Get("Authorization")
-		var (
-			token   string
-			isValid bool
-			user_id int64
-		)
-		if len(auth) > 0 {
-			token = strings.Split(auth, " ")[1]
-			isValid, user_id = validateToken(token)
-		} else {
-			isValid = false
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
 		}
 
+		// Labor records are kept five years unless USER_RETENTION_DAYS says otherwise
+		days, err := strconv.Atoi(getEnvVar("USER_RETENTION_DAYS"))
+		if err != nil || days < 0 {
+			days = 5 * 365
+		}
+		user, err := repo.PurgeUser(intid, time.Duration(days)*24*time.Hour)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		s.removePFP(user.PFP)
+
+		res := struct {
+			Message string `json:"message"`
+		}{localize(w, "user_purged")}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (s *Server) createClocking(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, user_id := s.authenticate(r)
 		if !isValid {
-			w.WriteHeader(http.StatusUnauthorized)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: "no autorizado"}
-			json.NewEncoder(w).Encode(msg)
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 
 		// Retrieve json
 		var clocking services.Clocking
-		err := json.NewDecoder(r.Body).Decode(&clocking)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: err.Error()}
-			json.NewEncoder(w).Encode(msg)
+		if err := decodeJSON(w, r, &clocking); err != nil {
+			writeError(w, err)
 			return
 		}
 		clocking.UserID = user_id
@@ -644,11 +1203,7 @@ func (s *Server) createClocking(repo *services.SQLiteRepository) http.HandlerFun
 		// Create clocking
 		clockingCreated, err := repo.CreateClocking(clocking)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: err.Error()}
-			json.NewEncoder(w).Encode(msg)
+			writeError(w, err)
 			return
 		}
 
@@ -656,7 +1211,7 @@ func (s *Server) createClocking(repo *services.SQLiteRepository) http.HandlerFun
 		res := struct {
 			Message  string            `json:"message"`
 			Clocking services.Clocking `json:"clocking"`
-		}{"Clocking registrado", *clockingCreated}
+		}{localize(w, "clocking_created"), *clockingCreated}
 
 		if err := json.NewEncoder(w).Encode(res); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -669,25 +1224,9 @@ func (s *Server) getClockings(repo *services.SQLiteRepository) http.HandlerFunc
 		w.Header().Set("Content-Type", "application/json")
 
 		// Auth
-		auth := r.Header.Get("Authorization")
-		var (
-			token   string
-			isValid bool
-			user_id int64
-		)
-		if len(auth) > 0 {
-			token = strings.Split(auth, " ")[1]
-			isValid, user_id = validateToken(token)
-		} else {
-			isValid = false
-		}
-
+		isValid, user_id := s.authenticate(r)
 		if !isValid {
-			w.WriteHeader(http.StatusUnauthorized)
-			msg := struct {
-				Message string `json:"message"`
-			}{Message: "no autorizado"}
-			json.NewEncoder(w).Encode(msg)
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 
@@ -704,3 +1243,59 @@ func (s *Server) getClockings(repo *services.SQLiteRepository) http.HandlerFunc
 		}
 	}
 }
+
+func (s *Server) importUsers(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		// Retrieve file, either as the "file" field of a form or as the body
+		var body io.Reader = r.Body
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				writeError(w, badRequest("invalid_form", err))
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+		invite, _ := strconv.ParseBool(r.URL.Query().Get("invite"))
+
+		// Import users
+		result, err := repo.ImportUsers(body, dryRun, invite)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Invite users to choose their own password
+		for _, row := range result.Rows {
+			if !invite || row.UserID == 0 {
+				continue
+			}
+			user, err := repo.GetUserById(row.UserID)
+			if err == nil {
+				err = s.inviteUser(user, w.Header().Get("Content-Language"))
+			}
+			if err != nil {
+				log.Printf("inviting user %d failed: %s\n", row.UserID, err)
+			}
+		}
+
+		if !dryRun && result.Failed > 0 {
+			w.WriteHeader(http.StatusMultiStatus)
+		}
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Package openapi builds the OpenAPI 3 document of the API from a table of
+// operations, deriving the schemas of bodies from the Go types that encode
+// them: their json tags name the properties and their validate tags, see
+// package validate, constrain them.
+package openapi
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Version is the version of the OpenAPI specification documents follow.
+const Version = "3.0.3"
+
+// Docs is a page that renders the document served at /openapi.json and lets
+// its operations be tried.
+//
+//go:embed docs.html
+var Docs []byte
+
+// Operation describes a route for New.
+type Operation struct {
+	// ID names the operation, by default after its method and path.
+	ID      string
+	Method  string
+	Path    string // As registered in the router, like /user/{id:[0-9]+}
+	Tag     string
+	Summary string
+	// Public operations need no bearer token.
+	Public bool
+	Query  []Param
+	// Body is a value of the type of the JSON body, or nil.
+	Body any
+	// Form are the fields of a multipart body, used when Body is nil.
+	Form []Param
+	// Response is a value of the type of the JSON response, or nil when
+	// Produces tells the media types of a binary one.
+	Response any
+	Produces []string
+}
+
+// Param is a query parameter or a form field. Type is a JSON schema type, or
+// "file" for uploads.
+type Param struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	Enum        []string
+	// JSON is a value of the type of a form field holding JSON, if it does.
+	JSON any
+}
+
+// Object is the schema of a JSON object with a property for every key, typed
+// like its value. It describes the anonymous envelopes of responses.
+type Object map[string]any
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+}
+
+// Info is the title and version of the API.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	Responses       map[string]*response       `json:"responses"`
+	SecuritySchemes map[string]*securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []*parameter          `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema   *Schema              `json:"schema"`
+	Encoding map[string]*encoding `json:"encoding,omitempty"`
+}
+
+type encoding struct {
+	ContentType string `json:"contentType"`
+}
+
+// New returns the document of operations, whose failures are responded with
+// a value of the type of apiError.
+func New(info Info, apiError any, operations []Operation) *Document {
+	d := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]*operation{},
+		Components: components{
+			Schemas:         map[string]*Schema{},
+			SecuritySchemes: map[string]*securityScheme{"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"}},
+		},
+	}
+	d.Components.Responses = map[string]*response{
+		"Error": {Description: "Error", Content: jsonContent(d.schemaOf(apiError))},
+	}
+	for _, op := range operations {
+		d.add(op)
+	}
+	return d
+}
+
+// Has tells whether the document describes the route registered with the
+// method and path template.
+func (d *Document) Has(method string, template string) bool {
+	path, _ := Path(template)
+	_, ok := d.Paths[path][strings.ToLower(method)]
+	return ok
+}
+
+// Operations returns the method and path of every operation, sorted.
+func (d *Document) Operations() []string {
+	var all []string
+	for path, methods := range d.Paths {
+		for method := range methods {
+			all = append(all, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(all)
+	return all
+}
+
+var pathVariable = regexp.MustCompile(`\{(\w+)(?::([^{}]*))?\}`)
+
+var alternatives = regexp.MustCompile(`^\w+(\|\w+)*$`)
+
+// Path turns a router path template into an OpenAPI path, without the
+// patterns of its variables, and returns the parameters of these.
+func Path(template string) (string, []*parameter) {
+	var params []*parameter
+	for _, match := range pathVariable.FindAllStringSubmatch(template, -1) {
+		name, pattern := match[1], match[2]
+		schema := &Schema{Type: "string"}
+		switch {
+		case name == "id" || pattern == "[0-9]+":
+			schema = &Schema{Type: "integer", Format: "int64"}
+		case pattern != "" && alternatives.MatchString(pattern):
+			schema.Enum = enum(schema.Type, strings.Split(pattern, "|"))
+		}
+		params = append(params, &parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return pathVariable.ReplaceAllString(template, "{$1}"), params
+}
+
+func (d *Document) add(op Operation) {
+	path, params := Path(op.Path)
+	method := strings.ToLower(op.Method)
+	o := &operation{
+		Summary:     op.Summary,
+		OperationID: op.ID,
+		Parameters:  params,
+		Responses:   map[string]*response{"default": {Ref: "#/components/responses/Error"}},
+		Security:    []map[string][]string{{"bearer": {}}},
+	}
+	if o.OperationID == "" {
+		o.OperationID = operationID(op.Method, path)
+	}
+	if op.Tag != "" {
+		o.Tags = []string{op.Tag}
+	}
+	if op.Public {
+		o.Security = []map[string][]string{}
+	}
+	for _, p := range op.Query {
+		o.Parameters = append(o.Parameters, &parameter{
+			Name: p.Name, In: "query", Description: p.Description, Required: p.Required, Schema: p.schema(),
+		})
+	}
+
+	switch {
+	case op.Body != nil:
+		o.RequestBody = &requestBody{Required: true, Content: jsonContent(d.schemaOf(op.Body))}
+	case len(op.Form) > 0:
+		form := &mediaType{Schema: &Schema{Type: "object", Properties: map[string]*Schema{}}}
+		for _, p := range op.Form {
+			form.Schema.Properties[p.Name] = p.schema()
+			form.Schema.Properties[p.Name].Description = p.Description
+			if p.JSON != nil {
+				form.Schema.Properties[p.Name] = d.schemaOf(p.JSON)
+				if form.Encoding == nil {
+					form.Encoding = map[string]*encoding{}
+				}
+				form.Encoding[p.Name] = &encoding{ContentType: "application/json"}
+			}
+			if p.Required {
+				form.Schema.Required = append(form.Schema.Required, p.Name)
+			}
+		}
+		o.RequestBody = &requestBody{Required: true, Content: map[string]*mediaType{"multipart/form-data": form}}
+	}
+
+	ok := &response{Description: http.StatusText(http.StatusOK)}
+	if op.Response != nil {
+		ok.Content = jsonContent(d.schemaOf(op.Response))
+	}
+	if len(op.Produces) > 0 {
+		ok.Content = map[string]*mediaType{}
+		for _, media := range op.Produces {
+			ok.Content[media] = &mediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+		}
+	}
+	o.Responses["200"] = ok
+
+	if d.Paths[path] == nil {
+		d.Paths[path] = map[string]*operation{}
+	}
+	if _, exists := d.Paths[path][method]; exists {
+		panic(fmt.Sprintf("openapi: %s %s described twice", op.Method, path))
+	}
+	d.Paths[path][method] = o
+}
+
+func (p Param) schema() *Schema {
+	switch p.Type {
+	case "file":
+		return &Schema{Type: "string", Format: "binary"}
+	case "":
+		return &Schema{Type: "string", Enum: enum("string", p.Enum)}
+	}
+	return &Schema{Type: p.Type, Enum: enum(p.Type, p.Enum)}
+}
+
+// operationID names an operation after its method and the fixed segments of
+// its path, like getUserProfile for GET /user/profile.
+func operationID(method string, path string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '.' }) {
+		if strings.HasPrefix(segment, "{") {
+			segment = "By" + strings.Trim(segment, "{}")
+		}
+		id += strings.ToUpper(segment[:1]) + segment[1:]
+	}
+	return id
+}
+
+func jsonContent(schema *Schema) map[string]*mediaType {
+	return map[string]*mediaType{"application/json": {Schema: schema}}
+}
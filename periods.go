@@ -0,0 +1,116 @@
+package pentview
+
+import (
+	"encoding/json"
+	"go-pentview/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) createPayPeriod(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		// Retrieve json
+		var period services.PayPeriod
+		if err := decodeJSON(w, r, &period); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Create period
+		periodCreated, err := repo.CreatePayPeriod(period)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response period
+		res := struct {
+			Message string             `json:"message"`
+			Period  services.PayPeriod `json:"period"`
+		}{localize(w, "pay_period_created"), *periodCreated}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+func (s *Server) getPayPeriods(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		periods, _ := repo.AllPayPeriods()
+		if len(periods) == 0 {
+			periods = []services.PayPeriod{}
+		}
+		data := struct {
+			Data []services.PayPeriod `json:"data"`
+		}{Data: periods}
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (s *Server) closePayPeriod(repo *services.SQLiteRepository) http.HandlerFunc {
+	return s.setPayPeriodState(repo, repo.ClosePayPeriod, "pay_period_closed")
+}
+func (s *Server) reopenPayPeriod(repo *services.SQLiteRepository) http.HandlerFunc {
+	return s.setPayPeriodState(repo, repo.ReopenPayPeriod, "pay_period_reopened")
+}
+
+func (s *Server) setPayPeriodState(repo *services.SQLiteRepository, apply func(int64, int64) (*services.PayPeriod, error), message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		// Retrieve id
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		period, err := apply(intid, admin_id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Response period
+		res := struct {
+			Message string             `json:"message"`
+			Period  services.PayPeriod `json:"period"`
+		}{localize(w, message), *period}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package pentview
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"go-pentview/services"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) getPersonalData(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Auth
+		isValid, user_id := s.authenticate(r)
+		if !isValid {
+			w.Header().Set("Content-Type", "application/json")
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		s.writePersonalData(w, repo, user_id)
+	}
+}
+func (s *Server) getUserPersonalData(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			w.Header().Set("Content-Type", "application/json")
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, err)
+			return
+		}
+		s.writePersonalData(w, repo, intid)
+	}
+}
+
+// writePersonalData responds a zip archive holding data.json, with every
+// record about the user, and its profile image when it has one.
+func (s *Server) writePersonalData(w http.ResponseWriter, repo *services.SQLiteRepository, user_id int64) {
+	data, err := repo.GetPersonalData(user_id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeMessage(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"personal-data-%d.zip\"", user_id))
+	archive := zip.NewWriter(w)
+	defer archive.Close()
+
+	f, err := archive.Create("data.json")
+	if err != nil {
+		log.Printf("personal data of %d failed: %s\n", user_id, err)
+		return
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		log.Printf("personal data of %d failed: %s\n", user_id, err)
+		return
+	}
+
+	name := pfpName(data.User.PFP)
+	if name == "" {
+		return
+	}
+	img, err := s.images.Open(name, 0)
+	if err != nil {
+		return
+	}
+	f, err = archive.Create("profile" + filepath.Ext(name))
+	if err != nil {
+		log.Printf("personal data of %d failed: %s\n", user_id, err)
+		return
+	}
+	if _, err := io.Copy(f, img); err != nil {
+		log.Printf("personal data of %d failed: %s\n", user_id, err)
+	}
+}
+
+func (s *Server) anonymizeUser(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, admin_id := s.authenticate(r)
+		if !isValid || !isAdmin(repo, admin_id) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		user, err := repo.AnonymizeUser(intid)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		s.removePFP(user.PFP)
+
+		writeMessage(w, http.StatusOK, "user_anonymized")
+	}
+}
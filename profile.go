@@ -0,0 +1,161 @@
+package pentview
+
+import (
+	"encoding/json"
+	"errors"
+	"go-pentview/images"
+	"go-pentview/services"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// imageURLExpiry is how long a signed profile image URL stays valid.
+const imageURLExpiry = 15 * time.Minute
+
+// putProfileImage uploads or replaces the profile image of the caller, sent
+// as the "image" file of a multipart request.
+func (s *Server) putProfileImage(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, user_id := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		pfp, release, err := s.uploadPFP(w, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if pfp == services.DefaultPFP {
+			release()
+			writeMessage(w, http.StatusBadRequest, "image_required")
+			return
+		}
+		s.setProfileImage(w, repo, user_id, pfp, release, "profile_image_updated")
+	}
+}
+
+// deleteProfileImage removes the profile image of the caller, who is shown
+// the default image again.
+func (s *Server) deleteProfileImage(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, user_id := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		s.setProfileImage(w, repo, user_id, services.DefaultPFP, func() {}, "profile_image_deleted")
+	}
+}
+
+// setProfileImage points the user to pfp, disposes of the image it replaces
+// and responds the updated profile. It calls release, of uploadPFP, once pfp
+// is referenced.
+func (s *Server) setProfileImage(w http.ResponseWriter, repo *services.SQLiteRepository, user_id int64, pfp string, release func(), message string) {
+	old, err := repo.SetPFP(user_id, pfp)
+	release()
+	if err != nil {
+		// The upload is not referenced by anyone
+		s.removePFP(pfp)
+		writeError(w, err)
+		return
+	}
+	if old != pfp {
+		s.removePFP(old)
+	}
+
+	profile, err := repo.GetProfileById(user_id)
+	if err != nil {
+		writeMessage(w, http.StatusNotFound, err.Error())
+		return
+	}
+	res := struct {
+		Message string        `json:"message"`
+		User    services.User `json:"user"`
+	}{localize(w, message), *profile}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// getProfileImageURL returns a signed, expiring URL of the profile image of a
+// user, the way to show it when images are private. Users get their own, and
+// admins and managers those of the users they manage.
+func (s *Server) getProfileImageURL(repo *services.SQLiteRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Auth
+		isValid, user_id := s.authenticate(r)
+		if !isValid {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		intid, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if user_id != intid && !canManage(repo, user_id, intid) {
+			writeMessage(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		var size int
+		if param := r.URL.Query().Get("size"); param != "" {
+			if size, err = strconv.Atoi(param); err != nil {
+				writeError(w, images.ErrInvalidSize)
+				return
+			}
+		}
+
+		user, err := repo.GetUserById(intid)
+		if err != nil {
+			writeMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		expiresAt := time.Now().Add(imageURLExpiry)
+		url := user.PFP
+		// Generated avatars are signed by the server itself
+		if strings.HasPrefix(user.PFP, "upload/avatar/") && s.privateImages {
+			url = s.signAvatar(user.PFP, imageURLExpiry)
+		} else if name := pfpName(user.PFP); name != "" {
+			url, err = s.images.SignedURL(name, size, imageURLExpiry)
+			switch {
+			case errors.Is(err, images.ErrInvalidName), errors.Is(err, images.ErrInvalidSize):
+				writeError(w, err)
+				return
+			case errors.Is(err, images.ErrNotFound):
+				writeMessage(w, http.StatusNotFound, err.Error())
+				return
+			case err != nil:
+				log.Printf("signing image %s failed: %s\n", name, err)
+				writeMessage(w, http.StatusInternalServerError, "image_sign_failed")
+				return
+			}
+		}
+
+		res := struct {
+			URL       string `json:"url"`
+			ExpiresAt string `json:"expiresAt"`
+		}{url, expiresAt.Format(time.RFC3339)}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -8,14 +8,19 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrInvalidCredentials = errors.New("wrong username or password")
+	ErrNotActivated       = errors.New("account not activated")
+)
+
 var (
 	QueryHashed                 = fmt.Sprintf("SELECT u.password FROM %s u WHERE u.email = ?", tableUsers)
 	QueryProfileWithCredentials = fmt.Sprintf("SELECT * FROM %s u JOIN %s r ON u.role_id_fk = r.role_id WHERE u.email = ?", tableUsers, tableRoles)
 )
 
 type Credentials struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required,max=254"`
+	Password string `json:"password" validate:"required,max=72"`
 }
 
 func (r *SQLiteRepository) CompareCredentials(credentials Credentials) (*User, error) {
@@ -23,27 +28,30 @@ func (r *SQLiteRepository) CompareCredentials(credentials Credentials) (*User, e
 	var hashed string
 	if err := row.Scan(&hashed); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("auth failed")
+			return nil, ErrInvalidCredentials
 		}
 		return nil, err
 	}
 
+	if hashed == "" {
+		return nil, ErrNotActivated
+	}
 	err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(credentials.Password))
 	if err != nil {
-		return nil, errors.New("wrong password")
+		return nil, ErrInvalidCredentials
 	}
 	row = r.db.QueryRow(QueryProfileWithCredentials, credentials.Username)
 
-	var (
-		user User
-		fk   string
-	)
-	if err := row.Scan(&user.UserID, &user.Name, &user.Last, &user.Email, &user.Password, &user.PFP, &user.CreatedAt, &fk, &user.Role.RoleID, &user.Role.Name, &user.Role.CreatedAt); err != nil {
+	user, err := scanUser(row)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotExists
 		}
 		return nil, err
 	}
+	if !user.Active {
+		return nil, ErrInactive
+	}
 	user.Password = ""
-	return &user, nil
+	return user, nil
 }
@@ -0,0 +1,378 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	tableEntitlements = "entitlements"
+	tableLedger       = "ledger"
+)
+
+const (
+	LedgerAccrual    = "accrual"
+	LedgerCarryOver  = "carryover"
+	LedgerYearEnd    = "yearend"
+	LedgerExpiry     = "expiry"
+	LedgerAdjustment = "adjustment"
+)
+
+var (
+	QuerySetEntitlement = fmt.Sprintf(`INSERT INTO %s(user_id_fk, role_id_fk, annual, carryOverLimit, carryOverExpiry, createdAt) values(?,?,?,?,?,?)
+		ON CONFLICT(user_id_fk, role_id_fk) DO UPDATE SET annual = excluded.annual, carryOverLimit = excluded.carryOverLimit, carryOverExpiry = excluded.carryOverExpiry`, tableEntitlements)
+	QueryReadEntitlementId     = fmt.Sprintf("SELECT entitlement_id FROM %s WHERE user_id_fk = ? AND role_id_fk = ?", tableEntitlements)
+	QueryReadEntitlements      = fmt.Sprintf("SELECT * FROM %s", tableEntitlements)
+	QueryReadEntitlementByUser = fmt.Sprintf(`SELECT e.* FROM %s e JOIN %s u ON e.user_id_fk = u.user_id OR (e.user_id_fk = 0 AND e.role_id_fk = u.role_id_fk)
+		WHERE u.user_id = ? ORDER BY e.user_id_fk DESC LIMIT 1`, tableEntitlements, tableUsers)
+	QueryDeleteEntitlement = fmt.Sprintf("DELETE FROM %s WHERE entitlement_id = ?", tableEntitlements)
+
+	QueryCreateLedgerEntry   = fmt.Sprintf("INSERT INTO %s(user_id_fk, amount, kind, period, reason, createdBy, createdAt) values(?,?,?,?,?,?,?)", tableLedger)
+	QueryReadLedger          = fmt.Sprintf("SELECT * FROM %s WHERE user_id_fk = ? ORDER BY entry_id", tableLedger)
+	QueryReadBalance         = fmt.Sprintf("SELECT COALESCE(SUM(amount), 0) FROM %s WHERE user_id_fk = ?", tableLedger)
+	QueryReadLedgerByPeriod  = fmt.Sprintf("SELECT COALESCE(SUM(amount), 0) FROM %s WHERE user_id_fk = ? AND kind = ? AND period LIKE ?", tableLedger)
+	QueryCountLedgerByPeriod = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE user_id_fk = ? AND kind = ? AND period = ?", tableLedger)
+	QueryReadDebitsSince     = fmt.Sprintf("SELECT COALESCE(SUM(amount), 0) FROM %s WHERE user_id_fk = ? AND amount < 0 AND kind NOT IN ('yearend', 'expiry') AND createdAt >= ?", tableLedger)
+	QueryReadUserIds         = fmt.Sprintf("SELECT user_id FROM %s WHERE active = 1", tableUsers)
+)
+
+// Entitlement is the yearly vacation allowance in days. It applies to a single
+// user when UserID is set, otherwise to every user holding RoleID. A user
+// entitlement always takes precedence over the one of its role.
+// CarryOverExpiry is the number of months of the new year during which carried
+// days can still be used, up to 11, zero meaning they never expire. Days
+// carried for the whole year are those that never expire: the next year close
+// caps them again.
+type Entitlement struct {
+	EntitlementID   int64   `json:"_id"`
+	UserID          int64   `json:"user"`
+	RoleID          int64   `json:"role"`
+	Annual          float64 `json:"annual" validate:"min=0"`
+	CarryOverLimit  float64 `json:"carryOverLimit" validate:"min=0"`
+	CarryOverExpiry int     `json:"carryOverExpiry" validate:"min=0,max=11"`
+	CreatedAt       string  `json:"createdAt"`
+}
+
+// LedgerEntry is a single credit (positive amount) or debit (negative amount)
+// of vacation days.
+type LedgerEntry struct {
+	EntryID   int64   `json:"_id"`
+	UserID    int64   `json:"user"`
+	Amount    float64 `json:"amount"`
+	Kind      string  `json:"kind"`
+	Period    string  `json:"period"`
+	Reason    string  `json:"reason"`
+	CreatedBy int64   `json:"createdBy"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+type Balance struct {
+	Balance     float64       `json:"balance"`
+	Entitlement *Entitlement  `json:"entitlement"`
+	Ledger      []LedgerEntry `json:"ledger"`
+}
+
+type Adjustment struct {
+	Amount float64 `json:"amount" validate:"required"`
+	Reason string  `json:"reason" validate:"required,max=500"`
+}
+
+func (r *SQLiteRepository) SetEntitlement(entitlement Entitlement) (*Entitlement, error) {
+	if entitlement.UserID == 0 && entitlement.RoleID == 0 {
+		return nil, invalid("entitlement_target_required")
+	}
+	if entitlement.UserID != 0 && entitlement.RoleID != 0 {
+		return nil, invalid("entitlement_target_ambiguous")
+	}
+	if entitlement.Annual < 0 || entitlement.CarryOverLimit < 0 || entitlement.CarryOverExpiry < 0 || entitlement.CarryOverExpiry > 11 {
+		return nil, invalid("invalid_entitlement")
+	}
+
+	entitlement.CreatedAt = time.Now().Format(time.RFC3339)
+	_, err := r.db.Exec(QuerySetEntitlement, entitlement.UserID, entitlement.RoleID, entitlement.Annual, entitlement.CarryOverLimit, entitlement.CarryOverExpiry, entitlement.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// LastInsertId is not reliable when the upsert updates an existing row
+	row := r.db.QueryRow(QueryReadEntitlementId, entitlement.UserID, entitlement.RoleID)
+	if err := row.Scan(&entitlement.EntitlementID); err != nil {
+		return nil, err
+	}
+
+	return &entitlement, nil
+}
+
+func (r *SQLiteRepository) AllEntitlements() ([]Entitlement, error) {
+	rows, err := r.db.Query(QueryReadEntitlements)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []Entitlement
+	for rows.Next() {
+		var entitlement Entitlement
+		if err := rows.Scan(&entitlement.EntitlementID, &entitlement.UserID, &entitlement.RoleID, &entitlement.Annual, &entitlement.CarryOverLimit, &entitlement.CarryOverExpiry, &entitlement.CreatedAt); err != nil {
+			return nil, err
+		}
+		all = append(all, entitlement)
+	}
+	return all, nil
+}
+
+func (r *SQLiteRepository) GetEntitlementByUser(user_id int64) (*Entitlement, error) {
+	row := r.db.QueryRow(QueryReadEntitlementByUser, user_id)
+
+	var entitlement Entitlement
+	if err := row.Scan(&entitlement.EntitlementID, &entitlement.UserID, &entitlement.RoleID, &entitlement.Annual, &entitlement.CarryOverLimit, &entitlement.CarryOverExpiry, &entitlement.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotExists
+		}
+		return nil, err
+	}
+	return &entitlement, nil
+}
+
+func (r *SQLiteRepository) DeleteEntitlement(id int64) error {
+	res, err := r.db.Exec(QueryDeleteEntitlement, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrDeleteFailed
+	}
+
+	return err
+}
+
+func (r *SQLiteRepository) CreateLedgerEntry(entry LedgerEntry) (*LedgerEntry, error) {
+	if entry.CreatedAt == "" {
+		entry.CreatedAt = time.Now().Format(time.RFC3339)
+	}
+	res, err := r.db.Exec(QueryCreateLedgerEntry, entry.UserID, entry.Amount, entry.Kind, entry.Period, entry.Reason, entry.CreatedBy, entry.CreatedAt)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) {
+			if errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+				return nil, ErrDuplicate
+			}
+		}
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	entry.EntryID = id
+
+	return &entry, nil
+}
+
+func (r *SQLiteRepository) AllLedgerEntries(user_id int64) ([]LedgerEntry, error) {
+	rows, err := r.db.Query(QueryReadLedger, user_id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []LedgerEntry
+	for rows.Next() {
+		var entry LedgerEntry
+		if err := rows.Scan(&entry.EntryID, &entry.UserID, &entry.Amount, &entry.Kind, &entry.Period, &entry.Reason, &entry.CreatedBy, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		all = append(all, entry)
+	}
+	return all, nil
+}
+
+func (r *SQLiteRepository) GetBalance(user_id int64) (*Balance, error) {
+	var balance Balance
+	if err := r.db.QueryRow(QueryReadBalance, user_id).Scan(&balance.Balance); err != nil {
+		return nil, err
+	}
+
+	entitlement, err := r.GetEntitlementByUser(user_id)
+	if err != nil && !errors.Is(err, ErrNotExists) {
+		return nil, err
+	}
+	balance.Entitlement = entitlement
+
+	balance.Ledger, err = r.AllLedgerEntries(user_id)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Ledger == nil {
+		balance.Ledger = []LedgerEntry{}
+	}
+	return &balance, nil
+}
+
+// AdjustBalance records a manual credit or debit made by an administrator.
+func (r *SQLiteRepository) AdjustBalance(user_id int64, admin_id int64, adjustment Adjustment) (*LedgerEntry, error) {
+	if adjustment.Amount == 0 {
+		return nil, invalid("zero_amount")
+	}
+	if adjustment.Reason == "" {
+		return nil, invalid("required", "reason")
+	}
+	if _, err := r.GetProfileById(user_id); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return r.CreateLedgerEntry(LedgerEntry{
+		UserID:    user_id,
+		Amount:    adjustment.Amount,
+		Kind:      LedgerAdjustment,
+		Period:    now.Format("2006-01"),
+		Reason:    adjustment.Reason,
+		CreatedBy: admin_id,
+		CreatedAt: now.Format(time.RFC3339),
+	})
+}
+
+// AccrueBalances credits the monthly share of the entitlement of every user for
+// the month of now. It is safe to call repeatedly: each period is only credited
+// once. On January the previous year is closed applying the carry-over limit,
+// and carried days left unused after the expiry month are written off.
+func (r *SQLiteRepository) AccrueBalances(now time.Time) error {
+	rows, err := r.db.Query(QueryReadUserIds)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := r.accrueUser(id, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) accrueUser(user_id int64, now time.Time) error {
+	entitlement, err := r.GetEntitlementByUser(user_id)
+	if errors.Is(err, ErrNotExists) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	year := now.Year()
+	period := now.Format("2006-01")
+	if now.Month() == time.January {
+		if err := r.closeYear(user_id, year, entitlement); err != nil {
+			return err
+		}
+	}
+	if entitlement.CarryOverExpiry > 0 && int(now.Month()) == entitlement.CarryOverExpiry+1 {
+		if err := r.expireCarryOver(user_id, year, period); err != nil {
+			return err
+		}
+	}
+
+	_, err = r.CreateLedgerEntry(LedgerEntry{
+		UserID: user_id,
+		Amount: entitlement.Annual / 12,
+		Kind:   LedgerAccrual,
+		Period: period,
+		Reason: "monthly accrual",
+	})
+	if errors.Is(err, ErrDuplicate) {
+		return nil
+	}
+	return err
+}
+
+// closeYear moves the remaining balance of the previous year into the new one,
+// capped by the carry-over limit of the entitlement. The yearend entry, unique
+// per user and period like every entry but adjustments, marks the year as
+// closed: it is written in the same transaction as the carry-over and the
+// balance it is read from, so closing twice, even concurrently, does nothing.
+func (r *SQLiteRepository) closeYear(user_id int64, year int, entitlement *Entitlement) error {
+	period := fmt.Sprintf("%d-01", year)
+	now := time.Now().Format(time.RFC3339)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var closed int
+	if err := tx.QueryRow(QueryCountLedgerByPeriod, user_id, LedgerYearEnd, period).Scan(&closed); err != nil {
+		return err
+	}
+	if closed > 0 {
+		return nil
+	}
+	var balance float64
+	if err := tx.QueryRow(QueryReadBalance, user_id).Scan(&balance); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(QueryCreateLedgerEntry, user_id, -balance, LedgerYearEnd, period, fmt.Sprintf("closing of %d", year-1), 0, now)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+			return nil
+		}
+		return err
+	}
+	carried := min(max(balance, 0), entitlement.CarryOverLimit)
+	if _, err := tx.Exec(QueryCreateLedgerEntry, user_id, carried, LedgerCarryOver, period, fmt.Sprintf("carry-over from %d", year-1), 0, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// expireCarryOver writes off the carried days that were not consumed by the
+// debits of the current year.
+func (r *SQLiteRepository) expireCarryOver(user_id int64, year int, period string) error {
+	var carried, debits float64
+	if err := r.db.QueryRow(QueryReadLedgerByPeriod, user_id, LedgerCarryOver, fmt.Sprintf("%d-%%", year)).Scan(&carried); err != nil {
+		return err
+	}
+	if err := r.db.QueryRow(QueryReadDebitsSince, user_id, fmt.Sprintf("%d-01-01", year)).Scan(&debits); err != nil {
+		return err
+	}
+
+	unused := carried + debits
+	if unused <= 0 {
+		return nil
+	}
+	_, err := r.CreateLedgerEntry(LedgerEntry{
+		UserID: user_id,
+		Amount: -unused,
+		Kind:   LedgerExpiry,
+		Period: period,
+		Reason: fmt.Sprintf("expiry of carry-over from %d", year-1),
+	})
+	if errors.Is(err, ErrDuplicate) {
+		return nil
+	}
+	return err
+}
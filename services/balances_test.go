@@ -0,0 +1,20 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetEntitlementExpiry(t *testing.T) {
+	repo, user := newTestRepository(t)
+	for _, expiry := range []int{0, 11} {
+		if _, err := repo.SetEntitlement(Entitlement{UserID: user.UserID, Annual: 22, CarryOverExpiry: expiry}); err != nil {
+			t.Errorf("expiry %d: %v", expiry, err)
+		}
+	}
+	_, err := repo.SetEntitlement(Entitlement{UserID: user.UserID, Annual: 22, CarryOverExpiry: 12})
+	var invalid *Invalid
+	if !errors.As(err, &invalid) || invalid.Code != "invalid_entitlement" {
+		t.Errorf("expiry 12: got %v, want invalid_entitlement", err)
+	}
+}
@@ -4,35 +4,57 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/mattn/go-sqlite3"
 )
 
 const tableClockings = "clockings"
 
+// ErrAlreadyClocked rejects two clockings of the same type in a row.
+var ErrAlreadyClocked = errors.New("clocking type already registered")
+
 var (
-	QueryCreateClocking   = fmt.Sprintf("INSERT INTO %s(type, date, user_id_fk) values(?,?,?)", tableClockings)
-	QueryReadClockings    = fmt.Sprintf("SELECT * FROM %s WHERE user_id_fk = ?", tableClockings)
-	QueryReadClockingById = fmt.Sprintf("SELECT * FROM %s WHERE clocking_id = ?", tableClockings)
-	QueryUpdateClocking   = fmt.Sprintf("UPDATE %s SET type = ?, date = ?, user_id_fk = ? WHERE clocking_id = ?", tableClockings)
-	QueryDeleteClocking   = fmt.Sprintf("DELETE FROM %s WHERE clocking_id = ?", tableClockings)
+	QueryCreateClocking    = fmt.Sprintf("INSERT INTO %s(type, date, user_id_fk, review) values(?,?,?,?)", tableClockings)
+	QueryReadClockings     = fmt.Sprintf("SELECT * FROM %s WHERE user_id_fk = ?", tableClockings)
+	QueryReadClockingById  = fmt.Sprintf("SELECT * FROM %s WHERE clocking_id = ?", tableClockings)
+	QueryUpdateClocking    = fmt.Sprintf("UPDATE %s SET type = ?, date = ?, user_id_fk = ? WHERE clocking_id = ?", tableClockings)
+	QueryDeleteClocking    = fmt.Sprintf("DELETE FROM %s WHERE clocking_id = ?", tableClockings)
+	QueryReadOpenClockings = fmt.Sprintf(`SELECT * FROM %[1]s c WHERE c.type = 'in'
+		AND c.clocking_id = (SELECT MAX(clocking_id) FROM %[1]s WHERE user_id_fk = c.user_id_fk)`, tableClockings)
+	QueryReadClockingsToReview = fmt.Sprintf("SELECT * FROM %s WHERE review = 1", tableClockings)
+	QueryReadReportClockings   = fmt.Sprintf("SELECT * FROM %s WHERE user_id_fk IN (%s)", tableClockings, QueryReadReportIds)
+	QueryReviewClocking        = fmt.Sprintf("UPDATE %s SET date = ?, review = 0 WHERE clocking_id = ? AND review = 1", tableClockings)
 )
 
 type Clocking struct {
 	ClockingID int64  `json:"_id"`
-	Type       string `json:"type"`
-	Date       string `json:"register"`
+	Type       string `json:"type" validate:"required,oneof=in out"`
+	Date       string `json:"register" validate:"required,datetime"`
 	UserID     int64  `json:"user,omitempty"`
+	Review     bool   `json:"review"`
+}
+
+// ClockingReview is the corrected time of a clocking under review.
+type ClockingReview struct {
+	Date string `json:"register" validate:"required,datetime"`
 }
 
 func (r *SQLiteRepository) CreateClocking(clocking Clocking) (*Clocking, error) {
+	if err := r.checkPeriodOpen(clocking.Date); err != nil {
+		return nil, err
+	}
 	all, _ := r.AllClockings(clocking.UserID)
 	if len(all) > 0 && all[len(all)-1].Type == clocking.Type {
-		msg := fmt.Sprintf("tipo \"%s\" ya registrado", clocking.Type)
-		return nil, errors.New(msg)
+		return nil, fmt.Errorf("%w: %s", ErrAlreadyClocked, clocking.Type)
 	}
+	return r.insertClocking(clocking)
+}
 
-	res, err := r.db.Exec(QueryCreateClocking, clocking.Type, clocking.Date, clocking.UserID)
+// insertClocking stores the clocking without checking it against the pay
+// periods and the previous clocking.
+func (r *SQLiteRepository) insertClocking(clocking Clocking) (*Clocking, error) {
+	res, err := r.db.Exec(QueryCreateClocking, clocking.Type, clocking.Date, clocking.UserID, clocking.Review)
 	if err != nil {
 		var sqliteErr sqlite3.Error
 		if errors.As(err, &sqliteErr) {
@@ -53,28 +75,14 @@ func (r *SQLiteRepository) CreateClocking(clocking Clocking) (*Clocking, error)
 }
 
 func (r *SQLiteRepository) AllClockings(user_id int64) ([]Clocking, error) {
-	rows, err := r.db.Query(QueryReadClockings, user_id)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var all []Clocking
-	for rows.Next() {
-		var clocking Clocking
-		if err := rows.Scan(&clocking.ClockingID, &clocking.Type, &clocking.Date, &clocking.UserID); err != nil {
-			return nil, err
-		}
-		all = append(all, clocking)
-	}
-	return all, nil
+	return r.queryClockings(QueryReadClockings, user_id)
 }
 
 func (r *SQLiteRepository) GetClockingById(id int64) (*Clocking, error) {
 	row := r.db.QueryRow(QueryReadClockingById, id)
 
 	var clocking Clocking
-	if err := row.Scan(&clocking.ClockingID, &clocking.Type, &clocking.Date, &clocking.UserID); err != nil {
+	if err := row.Scan(&clocking.ClockingID, &clocking.Type, &clocking.Date, &clocking.UserID, &clocking.Review); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotExists
 		}
@@ -85,7 +93,10 @@ func (r *SQLiteRepository) GetClockingById(id int64) (*Clocking, error) {
 
 func (r *SQLiteRepository) UpdateClocking(id int64, updated Clocking) (*Clocking, error) {
 	if id == 0 {
-		return nil, errors.New("invalid updated ID")
+		return nil, invalid("invalid_id")
+	}
+	if err := r.checkClockingOpen(id, updated.Date); err != nil {
+		return nil, err
 	}
 	res, err := r.db.Exec(QueryUpdateClocking, updated.Type, updated.Date, updated.UserID, id)
 	if err != nil {
@@ -105,6 +116,9 @@ func (r *SQLiteRepository) UpdateClocking(id int64, updated Clocking) (*Clocking
 }
 
 func (r *SQLiteRepository) DeleteClocking(id int64) error {
+	if err := r.checkClockingOpen(id, ""); err != nil {
+		return err
+	}
 	res, err := r.db.Exec(QueryDeleteClocking, id)
 	if err != nil {
 		return err
@@ -121,3 +135,110 @@ func (r *SQLiteRepository) DeleteClocking(id int64) error {
 
 	return err
 }
+
+func (r *SQLiteRepository) AllClockingsToReview() ([]Clocking, error) {
+	return r.queryClockings(QueryReadClockingsToReview)
+}
+
+// AllReportClockings returns the clockings of the direct and indirect reports
+// of the manager, only those flagged for review when review is set.
+func (r *SQLiteRepository) AllReportClockings(manager_id int64, review bool) ([]Clocking, error) {
+	query := QueryReadReportClockings
+	if review {
+		query += " AND review = 1"
+	}
+	return r.queryClockings(query+" ORDER BY user_id_fk, clocking_id", manager_id)
+}
+
+// ReviewClocking sets the definitive date of a clocking flagged for review and
+// clears the flag.
+func (r *SQLiteRepository) ReviewClocking(id int64, date string) (*Clocking, error) {
+	if _, err := time.Parse(time.RFC3339, date); err != nil {
+		return nil, invalid("invalid_datetime", "date")
+	}
+	if err := r.checkClockingOpen(id, date); err != nil {
+		return nil, err
+	}
+	res, err := r.db.Exec(QueryReviewClocking, date, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrUpdateFailed
+	}
+
+	return r.GetClockingById(id)
+}
+
+// CloseForgottenClockings registers an "out" flagged for review for every user
+// whose last clocking is an "in" older than cutoff. The generated "out" is dated
+// at the cutoff so the open interval does not keep growing. When that falls
+// inside a closed pay period the "out" is registered all the same, or the user
+// could never clock in again, and it is also returned in locked so that the
+// admins can reopen the period to review it.
+func (r *SQLiteRepository) CloseForgottenClockings(now time.Time, cutoff time.Duration) (closed []Clocking, locked []Clocking, err error) {
+	open, err := r.queryClockings(QueryReadOpenClockings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, clocking := range open {
+		in, err := time.Parse(time.RFC3339, clocking.Date)
+		if err != nil || now.Sub(in) < cutoff {
+			continue
+		}
+		forgotten := Clocking{Type: "out", Date: in.Add(cutoff).Format(time.RFC3339), UserID: clocking.UserID, Review: true}
+		out, err := r.CreateClocking(forgotten)
+		if errors.Is(err, ErrPeriodClosed) {
+			if out, err = r.insertClocking(forgotten); err == nil {
+				locked = append(locked, *out)
+			}
+		}
+		if err != nil {
+			return closed, locked, err
+		}
+		closed = append(closed, *out)
+	}
+	return closed, locked, nil
+}
+
+// checkClockingOpen makes sure that neither the stored date of the clocking nor
+// the date it is being changed to, unless empty, fall inside a closed pay
+// period.
+func (r *SQLiteRepository) checkClockingOpen(id int64, date string) error {
+	clocking, err := r.GetClockingById(id)
+	if err != nil {
+		return err
+	}
+	if err := r.checkPeriodOpen(clocking.Date); err != nil {
+		return err
+	}
+	if date == "" {
+		return nil
+	}
+	return r.checkPeriodOpen(date)
+}
+
+func (r *SQLiteRepository) queryClockings(query string, args ...any) ([]Clocking, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []Clocking
+	for rows.Next() {
+		var clocking Clocking
+		if err := rows.Scan(&clocking.ClockingID, &clocking.Type, &clocking.Date, &clocking.UserID, &clocking.Review); err != nil {
+			return nil, err
+		}
+		all = append(all, clocking)
+	}
+	return all, nil
+}
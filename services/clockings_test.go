@@ -0,0 +1,89 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestRepository returns a repository on a migrated database of a
+// temporary directory, with the admin role and a user of it.
+func newTestRepository(t *testing.T) (*SQLiteRepository, *User) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	repo := NewSQLiteRepository(db)
+	if err := repo.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRole(Role{Name: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	user, err := repo.CreateUser(UserToCreate{Name: "Test", Email: "test@example.com", PFP: DefaultPFP, Role: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, user
+}
+
+func TestDeleteClocking(t *testing.T) {
+	repo, user := newTestRepository(t)
+	open, err := repo.CreateClocking(Clocking{Type: "in", Date: "2024-05-06T08:00:00Z", UserID: user.UserID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, err := repo.CreateClocking(Clocking{Type: "out", Date: "2024-04-02T16:00:00Z", UserID: user.UserID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	period, err := repo.CreatePayPeriod(PayPeriod{Frequency: FrequencyMonthly, Start: "2024-04-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ClosePayPeriod(period.PeriodID, user.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.DeleteClocking(open.ClockingID); err != nil {
+		t.Errorf("delete a clocking of an open period: %v", err)
+	}
+	if _, err := repo.GetClockingById(open.ClockingID); !errors.Is(err, ErrNotExists) && !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("get the deleted clocking: got %v", err)
+	}
+	if err := repo.DeleteClocking(closed.ClockingID); !errors.Is(err, ErrPeriodClosed) {
+		t.Errorf("delete a clocking of a closed period: got %v, want ErrPeriodClosed", err)
+	}
+}
+
+func TestCloseForgottenClockingInClosedPeriod(t *testing.T) {
+	repo, user := newTestRepository(t)
+	if _, err := repo.CreateClocking(Clocking{Type: "in", Date: "2024-04-30T20:00:00Z", UserID: user.UserID}); err != nil {
+		t.Fatal(err)
+	}
+	period, err := repo.CreatePayPeriod(PayPeriod{Frequency: FrequencyMonthly, Start: "2024-05-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ClosePayPeriod(period.PeriodID, user.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, time.June, 3, 9, 0, 0, 0, time.UTC)
+	closed, locked, err := repo.CloseForgottenClockings(now, 12*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(closed) != 1 || len(locked) != 1 || closed[0].Date != "2024-05-01T08:00:00Z" || !closed[0].Review {
+		t.Fatalf("got closed %+v and locked %+v", closed, locked)
+	}
+	if _, err := repo.CreateClocking(Clocking{Type: "in", Date: now.Format(time.RFC3339), UserID: user.UserID}); err != nil {
+		t.Errorf("clock in after the forgotten clocking was closed: %v", err)
+	}
+}
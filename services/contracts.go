@@ -0,0 +1,242 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const tableContracts = "contracts"
+
+var (
+	QueryCreateContract        = fmt.Sprintf("INSERT INTO %s(user_id_fk, start, end, weeklyHours, workDays, overtime, createdAt) values(?,?,?,?,?,?,?)", tableContracts)
+	QueryReadContracts         = fmt.Sprintf("SELECT * FROM %s WHERE user_id_fk = ? ORDER BY start", tableContracts)
+	QueryDeleteContract        = fmt.Sprintf("DELETE FROM %s WHERE contract_id = ?", tableContracts)
+	QueryReadContractById      = fmt.Sprintf("SELECT * FROM %s WHERE contract_id = ?", tableContracts)
+	QueryCountOverlapContracts = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE user_id_fk = ? AND (end = '' OR end >= ?) AND (? = '' OR start <= ?)", tableContracts)
+)
+
+// Contract is the working-time agreement of a user between Start and End,
+// both inclusive days, an empty End meaning open-ended. WeeklyHours are spread
+// evenly over the first WorkDays days of the week starting on Monday. Hours
+// worked over the expected ones only count as overtime when Overtime is set.
+type Contract struct {
+	ContractID  int64   `json:"_id"`
+	UserID      int64   `json:"user"`
+	Start       string  `json:"start" validate:"required,date"`
+	End         string  `json:"end" validate:"omitempty,date"`
+	WeeklyHours float64 `json:"weeklyHours" validate:"required,min=0,max=168"`
+	WorkDays    int     `json:"workDays" validate:"omitempty,min=1,max=7"`
+	Overtime    bool    `json:"overtime"`
+	CreatedAt   string  `json:"createdAt"`
+}
+
+// Covers tells whether the contract is in force on day, formatted YYYY-MM-DD.
+func (c Contract) Covers(day string) bool {
+	return c.Start <= day && (c.End == "" || day <= c.End)
+}
+
+// ExpectedHours returns the hours the contract expects to be worked on day.
+func (c Contract) ExpectedHours(day time.Time) float64 {
+	// Monday is 1 and Sunday 7
+	weekday := (int(day.Weekday())+6)%7 + 1
+	if weekday > c.WorkDays {
+		return 0
+	}
+	return c.WeeklyHours / float64(c.WorkDays)
+}
+
+// BalanceDay compares the hours worked a day with those its contract expects.
+type BalanceDay struct {
+	Day      string  `json:"day"`
+	Worked   float64 `json:"worked"`
+	Expected float64 `json:"expected"`
+	Balance  float64 `json:"balance"`
+}
+
+// HourBalance is the plus (positive) or minus (negative) hours of a user over
+// a range of days.
+type HourBalance struct {
+	UserID   int64        `json:"user"`
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Worked   float64      `json:"worked"`
+	Expected float64      `json:"expected"`
+	Balance  float64      `json:"balance"`
+	Days     []BalanceDay `json:"days"`
+}
+
+func (r *SQLiteRepository) CreateContract(contract Contract) (*Contract, error) {
+	if _, err := r.GetUserById(contract.UserID); err != nil {
+		return nil, err
+	}
+	start, err := time.Parse(time.DateOnly, contract.Start)
+	if err != nil {
+		return nil, invalid("invalid_date", "start")
+	}
+	if contract.End != "" {
+		end, err := time.Parse(time.DateOnly, contract.End)
+		if err != nil {
+			return nil, invalid("invalid_date", "end")
+		}
+		if end.Before(start) {
+			return nil, invalid("end_before_start")
+		}
+	}
+	if contract.WeeklyHours <= 0 || contract.WeeklyHours > 168 {
+		return nil, invalid("invalid_weekly_hours")
+	}
+	if contract.WorkDays == 0 {
+		contract.WorkDays = 5
+	}
+	if contract.WorkDays < 1 || contract.WorkDays > 7 {
+		return nil, invalid("invalid_work_days")
+	}
+
+	var overlaps int
+	if err := r.db.QueryRow(QueryCountOverlapContracts, contract.UserID, contract.Start, contract.End, contract.End).Scan(&overlaps); err != nil {
+		return nil, err
+	}
+	if overlaps > 0 {
+		return nil, invalid("contract_overlap")
+	}
+
+	contract.CreatedAt = time.Now().Format(time.RFC3339)
+	res, err := r.db.Exec(QueryCreateContract, contract.UserID, contract.Start, contract.End, contract.WeeklyHours, contract.WorkDays, contract.Overtime, contract.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	contract.ContractID = id
+
+	return &contract, nil
+}
+
+func (r *SQLiteRepository) AllContracts(user_id int64) ([]Contract, error) {
+	rows, err := r.db.Query(QueryReadContracts, user_id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []Contract
+	for rows.Next() {
+		var contract Contract
+		if err := rows.Scan(&contract.ContractID, &contract.UserID, &contract.Start, &contract.End, &contract.WeeklyHours, &contract.WorkDays, &contract.Overtime, &contract.CreatedAt); err != nil {
+			return nil, err
+		}
+		all = append(all, contract)
+	}
+	return all, nil
+}
+
+func (r *SQLiteRepository) GetContractById(id int64) (*Contract, error) {
+	var contract Contract
+	err := r.db.QueryRow(QueryReadContractById, id).Scan(&contract.ContractID, &contract.UserID, &contract.Start, &contract.End, &contract.WeeklyHours, &contract.WorkDays, &contract.Overtime, &contract.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotExists
+		}
+		return nil, err
+	}
+	return &contract, nil
+}
+
+func (r *SQLiteRepository) DeleteContract(id int64) error {
+	res, err := r.db.Exec(QueryDeleteContract, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrDeleteFailed
+	}
+
+	return err
+}
+
+// contractOn returns the contract of contracts in force on day, if any.
+func contractOn(contracts []Contract, day string) *Contract {
+	for i := range contracts {
+		if contracts[i].Covers(day) {
+			return &contracts[i]
+		}
+	}
+	return nil
+}
+
+// dayBalance returns the hours contract expects on day and the plus or minus
+// hours of having worked the given ones. Without contract nothing is expected,
+// and surplus hours only count when the contract is eligible for overtime.
+func dayBalance(contract *Contract, day time.Time, worked float64) (float64, float64) {
+	var expected float64
+	if contract != nil {
+		expected = contract.ExpectedHours(day)
+	}
+	balance := worked - expected
+	if balance > 0 && (contract == nil || !contract.Overtime) {
+		balance = 0
+	}
+	return expected, balance
+}
+
+// GetHourBalance compares, day by day between from and to in loc, the hours
+// worked by the user with those expected by its contracts.
+func (r *SQLiteRepository) GetHourBalance(user_id int64, from string, to string, loc *time.Location) (*HourBalance, error) {
+	start, err := time.Parse(time.DateOnly, from)
+	if err != nil {
+		return nil, invalid("invalid_date", "from")
+	}
+	end, err := time.Parse(time.DateOnly, to)
+	if err != nil {
+		return nil, invalid("invalid_date", "to")
+	}
+	if end.Before(start) || end.Sub(start) > 366*24*time.Hour {
+		return nil, invalid("invalid_date_range")
+	}
+	if _, err := r.GetUserById(user_id); err != nil {
+		return nil, err
+	}
+
+	worked := map[string]float64{}
+	err = r.EachTimesheetDay(ClockingFilter{From: from, To: to, UserID: user_id}, loc, func(day TimesheetDay) error {
+		worked[day.Day] += day.Hours
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	contracts, err := r.AllContracts(user_id)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := HourBalance{UserID: user_id, From: from, To: to, Days: []BalanceDay{}}
+	for t := start; !t.After(end); t = t.AddDate(0, 0, 1) {
+		day := BalanceDay{Day: t.Format(time.DateOnly), Worked: worked[t.Format(time.DateOnly)]}
+		day.Expected, day.Balance = dayBalance(contractOn(contracts, day.Day), t, day.Worked)
+		day.Worked, day.Expected, day.Balance = round2(day.Worked), round2(day.Expected), round2(day.Balance)
+
+		balance.Worked += day.Worked
+		balance.Expected += day.Expected
+		balance.Balance += day.Balance
+		balance.Days = append(balance.Days, day)
+	}
+	balance.Worked, balance.Expected, balance.Balance = round2(balance.Worked), round2(balance.Expected), round2(balance.Balance)
+	return &balance, nil
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,372 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	tableFields      = "fields"
+	tableFieldValues = "field_values"
+)
+
+var (
+	QueryCreateField       = fmt.Sprintf("INSERT INTO %s(key, label, type, required, options, pattern, createdAt) values(?,?,?,?,?,?,?)", tableFields)
+	QueryReadFields        = fmt.Sprintf("SELECT * FROM %s ORDER BY field_id", tableFields)
+	QueryDeleteField       = fmt.Sprintf("DELETE FROM %s WHERE field_id = ?", tableFields)
+	QueryDeleteFieldValues = fmt.Sprintf("DELETE FROM %s WHERE field_id_fk = ?", tableFieldValues)
+	QuerySetFieldValue     = fmt.Sprintf(`INSERT INTO %s(user_id_fk, field_id_fk, value) values(?,?,?)
+		ON CONFLICT (user_id_fk, field_id_fk) DO UPDATE SET value = excluded.value`, tableFieldValues)
+	QueryDeleteFieldValue = fmt.Sprintf("DELETE FROM %s WHERE user_id_fk = ? AND field_id_fk = ?", tableFieldValues)
+	QueryReadFieldValues  = fmt.Sprintf("SELECT v.user_id_fk, f.key, v.value FROM %s v JOIN %s f ON v.field_id_fk = f.field_id", tableFieldValues, tableFields)
+	// QueryFilterFieldValue is the condition matching users whose field named
+	// key holds value
+	QueryFilterFieldValue = fmt.Sprintf("EXISTS (SELECT 1 FROM %s v JOIN %s f ON v.field_id_fk = f.field_id WHERE v.user_id_fk = u.user_id AND f.key = ? AND v.value = ?)", tableFieldValues, tableFields)
+)
+
+// FieldTypes are the accepted values of Field.Type.
+var FieldTypes = []string{"text", "number", "date", "boolean", "select"}
+
+var fieldKey = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// patterns caches the compiled Field.Pattern by source.
+var patterns sync.Map
+
+// compilePattern returns the compiled pattern, compiling it once.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+// Field is an admin defined employee attribute. Options lists the allowed
+// values of a select, Pattern is an optional regular expression text values
+// must match.
+type Field struct {
+	FieldID   int64    `json:"_id"`
+	Key       string   `json:"key" validate:"required,max=50"`
+	Label     string   `json:"label" validate:"required,max=100"`
+	Type      string   `json:"type" validate:"required,oneof=text number date boolean select"`
+	Required  bool     `json:"required"`
+	Options   []string `json:"options,omitempty"`
+	Pattern   string   `json:"pattern,omitempty" validate:"max=200"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+// Normalize validates value against the field type and returns it in its
+// stored form: numbers and booleans as formatted by strconv and dates as
+// YYYY-MM-DD.
+func (f Field) Normalize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch f.Type {
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", invalid("not_a_number", f.Key)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case "date":
+		if _, err := time.Parse(time.DateOnly, value); err != nil {
+			return "", invalid("invalid_date", f.Key)
+		}
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", invalid("not_a_boolean", f.Key)
+		}
+		return strconv.FormatBool(b), nil
+	case "select":
+		for _, option := range f.Options {
+			if option == value {
+				return value, nil
+			}
+		}
+		return "", invalid("not_an_option", f.Key, strings.Join(f.Options, ", "))
+	case "text":
+		if f.Pattern == "" {
+			break
+		}
+		// A pattern stored without CreateField is the fault of the server
+		re, err := compilePattern(f.Pattern)
+		if err != nil {
+			return "", fmt.Errorf("pattern of field %s: %w", f.Key, err)
+		}
+		if !re.MatchString(value) {
+			return "", invalid("pattern_mismatch", f.Key, f.Pattern)
+		}
+	}
+	return value, nil
+}
+
+func (r *SQLiteRepository) CreateField(field Field) (*Field, error) {
+	if !fieldKey.MatchString(field.Key) {
+		return nil, invalid("invalid_field_key")
+	}
+	if field.Label == "" {
+		field.Label = field.Key
+	}
+	valid := false
+	for _, t := range FieldTypes {
+		valid = valid || t == field.Type
+	}
+	if !valid {
+		return nil, invalid("invalid_field_type", strings.Join(FieldTypes, ", "))
+	}
+	if field.Type == "select" && len(field.Options) == 0 {
+		return nil, invalid("select_without_options")
+	}
+	if field.Type != "select" {
+		field.Options = nil
+	}
+	if field.Pattern != "" {
+		if field.Type != "text" {
+			return nil, invalid("pattern_not_text")
+		}
+		if _, err := regexp.Compile(field.Pattern); err != nil {
+			return nil, invalid("invalid_pattern", err)
+		}
+	}
+
+	options, err := json.Marshal(field.Options)
+	if err != nil {
+		return nil, err
+	}
+	field.CreatedAt = time.Now().Format(time.RFC3339)
+	res, err := r.db.Exec(QueryCreateField, field.Key, field.Label, field.Type, field.Required, string(options), field.Pattern, field.CreatedAt)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) {
+			if errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+				return nil, ErrDuplicate
+			}
+		}
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	field.FieldID = id
+
+	return &field, nil
+}
+
+func (r *SQLiteRepository) AllFields() ([]Field, error) {
+	rows, err := r.db.Query(QueryReadFields)
+	if err != nil {
+		return nil, err
+	}
+	return scanFields(rows)
+}
+
+// scanFields reads and closes rows of QueryReadFields.
+func scanFields(rows *sql.Rows) ([]Field, error) {
+	defer rows.Close()
+
+	var all []Field
+	for rows.Next() {
+		var (
+			field   Field
+			options string
+		)
+		if err := rows.Scan(&field.FieldID, &field.Key, &field.Label, &field.Type, &field.Required, &options, &field.Pattern, &field.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(options), &field.Options); err != nil {
+			return nil, err
+		}
+		all = append(all, field)
+	}
+	return all, nil
+}
+
+// DeleteField deletes the definition together with the values stored for it.
+func (r *SQLiteRepository) DeleteField(id int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(QueryDeleteFieldValues, id); err != nil {
+		return err
+	}
+	res, err := tx.Exec(QueryDeleteField, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrDeleteFailed
+	}
+
+	return tx.Commit()
+}
+
+// newFieldValues normalizes the custom field values of a new user, keyed by
+// field key, and checks that every required field has one. It returns the
+// values to store by field id.
+func newFieldValues(fields []Field, values map[string]string) (map[int64]string, error) {
+	byKey := map[string]Field{}
+	for _, field := range fields {
+		byKey[field.Key] = field
+	}
+	stored := map[int64]string{}
+	for key, value := range values {
+		field, ok := byKey[key]
+		if !ok {
+			return nil, invalid("unknown_custom_field", key)
+		}
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		normalized, err := field.Normalize(value)
+		if err != nil {
+			return nil, err
+		}
+		stored[field.FieldID] = normalized
+	}
+	for _, field := range fields {
+		if _, ok := stored[field.FieldID]; field.Required && !ok {
+			return nil, invalid("required", field.Key)
+		}
+	}
+	return stored, nil
+}
+
+// SetUserFields merges values, keyed by field key, into the custom fields of
+// the user. An empty value clears the field. Once merged every required field
+// must have a value.
+func (r *SQLiteRepository) SetUserFields(user_id int64, values map[string]string) (*User, error) {
+	user, err := r.GetUserById(user_id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Read in the transaction, so a field deleted meanwhile is unknown rather
+	// than left with an orphan value
+	rows, err := tx.Query(QueryReadFields)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := scanFields(rows)
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]Field{}
+	for _, field := range fields {
+		byKey[field.Key] = field
+	}
+
+	for key, value := range values {
+		field, ok := byKey[key]
+		if !ok {
+			return nil, invalid("unknown_custom_field", key)
+		}
+		if strings.TrimSpace(value) == "" {
+			if _, err := tx.Exec(QueryDeleteFieldValue, user_id, field.FieldID); err != nil {
+				return nil, err
+			}
+			delete(user.Fields, key)
+			continue
+		}
+		normalized, err := field.Normalize(value)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(QuerySetFieldValue, user_id, field.FieldID, normalized); err != nil {
+			return nil, err
+		}
+		user.Fields[key] = normalized
+	}
+	for _, field := range fields {
+		if field.Required && user.Fields[field.Key] == "" {
+			return nil, invalid("required", field.Key)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// loadFields fills the custom fields of users with a single query.
+func (r *SQLiteRepository) loadFields(users ...*User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*User, len(users))
+	args := make([]any, len(users))
+	for i, user := range users {
+		user.Fields = map[string]string{}
+		byID[user.UserID] = user
+		args[i] = user.UserID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(users)), ",")
+	rows, err := r.db.Query(QueryReadFieldValues+" WHERE v.user_id_fk IN ("+placeholders+")", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			user_id    int64
+			key, value string
+		)
+		if err := rows.Scan(&user_id, &key, &value); err != nil {
+			return err
+		}
+		byID[user_id].Fields[key] = value
+	}
+	return rows.Err()
+}
+
+// fieldFilter returns the conditions matching users whose custom fields hold
+// the given values, in a stable order.
+func fieldFilter(values map[string]string) ([]string, []any) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var (
+		where []string
+		args  []any
+	)
+	for _, key := range keys {
+		where = append(where, QueryFilterFieldValue)
+		args = append(args, key, values[key])
+	}
+	return where, args
+}
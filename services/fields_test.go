@@ -0,0 +1,40 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeStoredPattern(t *testing.T) {
+	field := Field{Key: "badge", Type: "text", Pattern: "^B-[0-9]+$"}
+	if _, err := field.Normalize("B-12"); err != nil {
+		t.Errorf("matching value: got %v", err)
+	}
+	var rule *Invalid
+	if _, err := field.Normalize("12"); !errors.As(err, &rule) || rule.Code != "pattern_mismatch" {
+		t.Errorf("mismatching value: got %v, want pattern_mismatch", err)
+	}
+
+	// Edited in the database, where CreateField does not check it
+	field.Pattern = "(["
+	if _, err := field.Normalize("B-12"); err == nil || errors.As(err, &rule) {
+		t.Errorf("invalid stored pattern: got %v, want an error of the server", err)
+	}
+}
+
+func TestSetUserFieldsDeletedField(t *testing.T) {
+	repo, user := newTestRepository(t)
+	field, err := repo.CreateField(Field{Key: "badge", Label: "Badge", Type: "text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteField(field.FieldID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.SetUserFields(user.UserID, map[string]string{"badge": "B-12"})
+	var rule *Invalid
+	if !errors.As(err, &rule) || rule.Code != "unknown_custom_field" {
+		t.Errorf("set a deleted field: got %v, want unknown_custom_field", err)
+	}
+}
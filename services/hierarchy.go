@@ -0,0 +1,288 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	tableDepartments = "departments"
+	tableTeams       = "teams"
+)
+
+var (
+	QueryCreateDepartment = fmt.Sprintf("INSERT INTO %s(name, createdAt) values(?,?)", tableDepartments)
+	QueryReadDepartments  = fmt.Sprintf("SELECT * FROM %s", tableDepartments)
+	QueryDeleteDepartment = fmt.Sprintf("DELETE FROM %s WHERE department_id = ? AND NOT EXISTS (SELECT 1 FROM %s WHERE department_id_fk = ?)", tableDepartments, tableTeams)
+
+	QueryCreateTeam   = fmt.Sprintf("INSERT INTO %s(name, department_id_fk, createdAt) SELECT ?, department_id, ? FROM %s WHERE department_id = ?", tableTeams, tableDepartments)
+	QueryReadTeams    = fmt.Sprintf("SELECT * FROM %s", tableTeams)
+	QueryReadTeamById = fmt.Sprintf("SELECT * FROM %s WHERE team_id = ?", tableTeams)
+	QueryDeleteTeam   = fmt.Sprintf("DELETE FROM %s WHERE team_id = ? AND NOT EXISTS (SELECT 1 FROM %s WHERE team_id_fk = ?)", tableTeams, tableUsers)
+
+	QuerySetHierarchy = fmt.Sprintf("UPDATE %s SET team_id_fk = ?, manager_id_fk = ? WHERE user_id = ?", tableUsers)
+	QueryReadManagers = fmt.Sprintf("SELECT manager_id_fk FROM %s WHERE user_id = ?", tableUsers)
+	// QueryReadReportIds walks down the hierarchy returning direct and indirect
+	// reports of a manager
+	QueryReadReportIds = fmt.Sprintf(`WITH RECURSIVE reports(id) AS (
+			SELECT user_id FROM %[1]s WHERE manager_id_fk = ?
+			UNION
+			SELECT u.user_id FROM %[1]s u JOIN reports ON u.manager_id_fk = reports.id
+		) SELECT id FROM reports`, tableUsers)
+	QueryCountReports = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE manager_id_fk = ?", tableUsers)
+	QueryReadReports  = fmt.Sprintf("%s WHERE u.user_id IN (%s) ORDER BY u.user_id", QueryReadUser, QueryReadReportIds)
+)
+
+type Department struct {
+	DepartmentID int64  `json:"_id"`
+	Name         string `json:"name" validate:"required,max=100"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+type Team struct {
+	TeamID       int64  `json:"_id"`
+	Name         string `json:"name" validate:"required,max=100"`
+	DepartmentID int64  `json:"department" validate:"required"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+// Hierarchy places a user in a team and under a manager, zero meaning none.
+type Hierarchy struct {
+	TeamID    int64 `json:"team" validate:"min=0"`
+	ManagerID int64 `json:"manager" validate:"min=0"`
+}
+
+func (r *SQLiteRepository) CreateDepartment(department Department) (*Department, error) {
+	if department.Name == "" {
+		return nil, invalid("required", "name")
+	}
+	department.CreatedAt = time.Now().Format(time.RFC3339)
+	res, err := r.db.Exec(QueryCreateDepartment, department.Name, department.CreatedAt)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) {
+			if errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+				return nil, ErrDuplicate
+			}
+		}
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	department.DepartmentID = id
+
+	return &department, nil
+}
+
+func (r *SQLiteRepository) AllDepartments() ([]Department, error) {
+	rows, err := r.db.Query(QueryReadDepartments)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []Department
+	for rows.Next() {
+		var department Department
+		if err := rows.Scan(&department.DepartmentID, &department.Name, &department.CreatedAt); err != nil {
+			return nil, err
+		}
+		all = append(all, department)
+	}
+	return all, nil
+}
+
+// DeleteDepartment only deletes departments without teams.
+func (r *SQLiteRepository) DeleteDepartment(id int64) error {
+	res, err := r.db.Exec(QueryDeleteDepartment, id, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrDeleteFailed
+	}
+
+	return err
+}
+
+func (r *SQLiteRepository) CreateTeam(team Team) (*Team, error) {
+	if team.Name == "" {
+		return nil, invalid("required", "name")
+	}
+	team.CreatedAt = time.Now().Format(time.RFC3339)
+	res, err := r.db.Exec(QueryCreateTeam, team.Name, team.CreatedAt, team.DepartmentID)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) {
+			if errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+				return nil, ErrDuplicate
+			}
+		}
+		return nil, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, invalid("department_not_found", team.DepartmentID)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	team.TeamID = id
+
+	return &team, nil
+}
+
+func (r *SQLiteRepository) AllTeams() ([]Team, error) {
+	rows, err := r.db.Query(QueryReadTeams)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []Team
+	for rows.Next() {
+		var team Team
+		if err := rows.Scan(&team.TeamID, &team.Name, &team.DepartmentID, &team.CreatedAt); err != nil {
+			return nil, err
+		}
+		all = append(all, team)
+	}
+	return all, nil
+}
+
+// DeleteTeam only deletes teams without members.
+func (r *SQLiteRepository) DeleteTeam(id int64) error {
+	res, err := r.db.Exec(QueryDeleteTeam, id, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrDeleteFailed
+	}
+
+	return err
+}
+
+// SetHierarchy moves the user to a team and under a manager. The manager must
+// be an active user and cannot be the user itself or one of its reports.
+func (r *SQLiteRepository) SetHierarchy(user_id int64, hierarchy Hierarchy) (*User, error) {
+	if _, err := r.GetUserById(user_id); err != nil {
+		return nil, err
+	}
+	if hierarchy.TeamID != 0 {
+		var team Team
+		err := r.db.QueryRow(QueryReadTeamById, hierarchy.TeamID).Scan(&team.TeamID, &team.Name, &team.DepartmentID, &team.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, invalid("team_not_found", hierarchy.TeamID)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if hierarchy.ManagerID != 0 {
+		manager, err := r.GetUserById(hierarchy.ManagerID)
+		if errors.Is(err, ErrNotExists) {
+			return nil, invalid("manager_not_found", hierarchy.ManagerID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !manager.Active {
+			return nil, ErrInactive
+		}
+		if manager.UserID == user_id {
+			return nil, invalid("self_manager")
+		}
+		isReport, err := r.IsManagerOf(user_id, manager.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if isReport {
+			return nil, invalid("manager_is_report")
+		}
+	}
+
+	if _, err := r.db.Exec(QuerySetHierarchy, hierarchy.TeamID, hierarchy.ManagerID, user_id); err != nil {
+		return nil, err
+	}
+	return r.GetUserById(user_id)
+}
+
+// IsManagerOf tells whether user_id reports, directly or not, to manager_id.
+func (r *SQLiteRepository) IsManagerOf(manager_id int64, user_id int64) (bool, error) {
+	// Walk up from the user, the visited set guards against a corrupted cycle
+	visited := map[int64]bool{}
+	for id := user_id; id != 0 && !visited[id]; {
+		visited[id] = true
+		var next int64
+		if err := r.db.QueryRow(QueryReadManagers, id).Scan(&next); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, nil
+			}
+			return false, err
+		}
+		if next == manager_id {
+			return true, nil
+		}
+		id = next
+	}
+	return false, nil
+}
+
+// HasReports tells whether someone reports directly to the manager.
+func (r *SQLiteRepository) HasReports(manager_id int64) (bool, error) {
+	var count int
+	if err := r.db.QueryRow(QueryCountReports, manager_id).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// AllReports returns the direct and indirect reports of the manager.
+func (r *SQLiteRepository) AllReports(manager_id int64) ([]User, error) {
+	rows, err := r.db.Query(QueryReadReports, manager_id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		user.Password = ""
+		all = append(all, *user)
+	}
+	users := make([]*User, len(all))
+	for i := range all {
+		users[i] = &all[i]
+	}
+	return all, r.loadFields(users...)
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"go-pentview/i18n"
+	"go-pentview/validate"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var QueryCountUsersByEmail = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE LOWER(email) = LOWER(?)", tableUsers)
+
+// importColumns maps the accepted CSV headers to the attribute they hold.
+var importColumns = map[string]string{
+	"name":         "name",
+	"firstname":    "name",
+	"last":         "last",
+	"lastname":     "last",
+	"email":        "email",
+	"role":         "role",
+	"code":         "code",
+	"employeecode": "code",
+}
+
+const DefaultPFP = "upload/nopfp.png"
+
+// AvatarPFP is the path of the generated avatar shown instead of DefaultPFP.
+func AvatarPFP(id int64) string {
+	return fmt.Sprintf("upload/avatar/%d.png", id)
+}
+
+// ImportRow is the outcome of a single CSV line. TemporaryPassword is only
+// returned once, when the user is actually created.
+type ImportRow struct {
+	Line              int      `json:"line"`
+	Email             string   `json:"email"`
+	UserID            int64    `json:"_id,omitempty"`
+	TemporaryPassword string   `json:"temporaryPassword,omitempty"`
+	Errors            []string `json:"errors,omitempty"`
+}
+
+type ImportResult struct {
+	DryRun  bool        `json:"dryRun"`
+	Created int         `json:"created"`
+	Failed  int         `json:"failed"`
+	Rows    []ImportRow `json:"rows"`
+}
+
+type importUser struct {
+	index  int
+	user   UserToCreate
+	roleID int64
+	values map[int64]string
+}
+
+// ImportUsers reads a CSV with a header row naming the columns name, last,
+// email, role (its name), the optional code and the custom fields as
+// field.<key>, every required one with a value. Every line is validated and,
+// unless dryRun is set, the valid ones are created together in a single
+// transaction. With invite the users are created without password, to be
+// invited by the caller, otherwise a temporary password is generated.
+func (r *SQLiteRepository) ImportUsers(reader io.Reader, dryRun bool, invite bool) (*ImportResult, error) {
+	cr := csv.NewReader(reader)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, invalid("invalid_csv", err)
+	}
+	columns := map[string]int{}
+	fieldColumns := map[string]int{}
+	for i, name := range header {
+		if key, ok := strings.CutPrefix(strings.TrimSpace(name), "field."); ok {
+			fieldColumns[key] = i
+			continue
+		}
+		key := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "_", ""))
+		if column, ok := importColumns[key]; ok {
+			columns[column] = i
+		}
+	}
+	for _, column := range []string{"name", "last", "email", "role"} {
+		if _, ok := columns[column]; !ok {
+			return nil, invalid("csv_missing_column", column)
+		}
+	}
+
+	roles, err := r.AllRoles()
+	if err != nil {
+		return nil, err
+	}
+	roleIds := map[string]int64{}
+	for _, role := range roles {
+		roleIds[role.Name] = role.RoleID
+	}
+	fields, err := r.AllFields()
+	if err != nil {
+		return nil, err
+	}
+	for key := range fieldColumns {
+		known := false
+		for _, f := range fields {
+			known = known || f.Key == key
+		}
+		if !known {
+			return nil, invalid("unknown_custom_field", key)
+		}
+	}
+
+	result := ImportResult{DryRun: dryRun, Rows: []ImportRow{}}
+	var valid []importUser
+	seen := map[string]int{}
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, invalid("invalid_csv", err)
+		}
+		field := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row := ImportRow{Line: line, Email: strings.ToLower(field("email"))}
+		user := UserToCreate{Name: field("name"), Last: field("last"), Email: row.Email, PFP: DefaultPFP, EmployeeCode: field("code"), Role: field("role")}
+		// The rules of the API, with the fields named after their column
+		var rules validate.Errors
+		if err := validate.Struct(user); err != nil && !errors.As(err, &rules) {
+			return nil, err
+		}
+		validEmail := true
+		for _, rule := range rules {
+			validEmail = validEmail && rule.Field != "email"
+			row.Errors = append(row.Errors, importColumns[strings.ToLower(rule.Field)]+" "+ruleMessage(rule))
+		}
+		if user.Last == "" {
+			row.Errors = append(row.Errors, "last is required")
+		}
+		if previous, ok := seen[user.Email]; validEmail && ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("email repeated from line %d", previous))
+		} else if validEmail {
+			seen[user.Email] = line
+			var count int
+			if err := r.db.QueryRow(QueryCountUsersByEmail, user.Email).Scan(&count); err != nil {
+				return nil, err
+			}
+			if count > 0 {
+				row.Errors = append(row.Errors, "email already registered")
+			}
+		}
+		roleID, ok := roleIds[strings.ToUpper(user.Role)]
+		if !ok && user.Role != "" {
+			row.Errors = append(row.Errors, fmt.Sprintf("role %q does not exist", field("role")))
+		}
+		user.Fields = map[string]string{}
+		for key, i := range fieldColumns {
+			if i < len(record) {
+				user.Fields[key] = strings.TrimSpace(record[i])
+			}
+		}
+		values, err := newFieldValues(fields, user.Fields)
+		if err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+
+		result.Rows = append(result.Rows, row)
+		if len(row.Errors) > 0 {
+			result.Failed++
+			continue
+		}
+		valid = append(valid, importUser{index: len(result.Rows) - 1, user: user, roleID: roleID, values: values})
+	}
+
+	if dryRun || len(valid) == 0 {
+		return &result, nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Format(time.RFC3339)
+	for _, v := range valid {
+		var password, hashed string
+		if !invite {
+			if password, err = temporaryPassword(); err != nil {
+				return nil, err
+			}
+			b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			if err != nil {
+				return nil, err
+			}
+			hashed = string(b)
+		}
+		res, err := tx.Exec(QueryCreateUser, v.user.Name, v.user.Last, v.user.Email, hashed, v.user.PFP, now, v.user.EmployeeCode, v.roleID)
+		if err != nil {
+			return nil, err
+		}
+		row := &result.Rows[v.index]
+		row.UserID, err = res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		for field_id, value := range v.values {
+			if _, err := tx.Exec(QuerySetFieldValue, row.UserID, field_id, value); err != nil {
+				return nil, err
+			}
+		}
+		row.TemporaryPassword = password
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	result.Created = len(valid)
+
+	return &result, nil
+}
+
+func temporaryPassword() (string, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ruleMessage is the English message of a rule broken by an imported row.
+func ruleMessage(rule validate.Error) string {
+	if rule.Param != "" {
+		return i18n.T("en", "rule_"+rule.Rule, rule.Param)
+	}
+	return i18n.T("en", "rule_"+rule.Rule)
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestImportUsersRules(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	csv := "name,last,email,role,code\n" +
+		"Ana,Ruiz,ana@example.com,admin,E-1\n" +
+		strings.Repeat("x", 101) + ",Ruiz,long@example.com,admin,E-1234567890\n" +
+		"Eva,Gil,Eva <eva@example.com>,admin,\n"
+
+	result, err := repo.ImportUsers(strings.NewReader(csv), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failed != 2 {
+		t.Fatalf("got %d failed rows, want 2: %+v", result.Failed, result.Rows)
+	}
+	if errs := result.Rows[0].Errors; len(errs) != 0 {
+		t.Errorf("valid row: got errors %v", errs)
+	}
+	want := []string{"name must have at most 100 characters", "code must have at most 10 characters"}
+	if errs := result.Rows[1].Errors; !slices.Equal(errs, want) {
+		t.Errorf("row over the limits: got errors %v, want %v", errs, want)
+	}
+	want = []string{"email must be a valid email"}
+	if errs := result.Rows[2].Errors; !slices.Equal(errs, want) {
+		t.Errorf("row with a display name: got errors %v, want %v", errs, want)
+	}
+}
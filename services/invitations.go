@@ -0,0 +1,126 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const tableInvitations = "invitations"
+
+var ErrInvalidToken = errors.New("invitation is invalid or expired")
+
+var (
+	QueryCreateInvitation      = fmt.Sprintf("INSERT INTO %s(user_id_fk, token, expiresAt, createdAt) values(?,?,?,?)", tableInvitations)
+	QueryRevokeInvitations     = fmt.Sprintf("DELETE FROM %s WHERE user_id_fk = ? AND usedAt = ''", tableInvitations)
+	QueryReadInvitationByToken = fmt.Sprintf("SELECT invitation_id, user_id_fk, expiresAt FROM %s WHERE token = ? AND usedAt = ''", tableInvitations)
+	QueryUseInvitation         = fmt.Sprintf("UPDATE %s SET usedAt = ? WHERE invitation_id = ? AND usedAt = ''", tableInvitations)
+	QuerySetPassword           = fmt.Sprintf("UPDATE %s SET password = ? WHERE user_id = ?", tableUsers)
+)
+
+type Activation struct {
+	Token    string `json:"token" validate:"required,max=128"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// CreateInvitation issues a new single use activation token for the user,
+// revoking the ones still pending. Only the hash of the token is stored with
+// the invitation; the mail carrying the token drops its body once delivered.
+func (r *SQLiteRepository) CreateInvitation(user_id int64, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(QueryRevokeInvitations, user_id); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if _, err := tx.Exec(QueryCreateInvitation, user_id, hashToken(token), now.Add(ttl).Format(time.RFC3339), now.Format(time.RFC3339)); err != nil {
+		return "", err
+	}
+	return token, tx.Commit()
+}
+
+// ActivateUser consumes the invitation token setting the password chosen by the
+// employee. Deactivated users fail with ErrInactive.
+func (r *SQLiteRepository) ActivateUser(activation Activation) (*User, error) {
+	if len(activation.Password) < 8 {
+		return nil, invalid("password_too_short")
+	}
+
+	var (
+		id        int64
+		user_id   int64
+		expiresAt string
+	)
+	row := r.db.QueryRow(QueryReadInvitationByToken, hashToken(activation.Token))
+	if err := row.Scan(&id, &user_id, &expiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if expiresAt < time.Now().Format(time.RFC3339) {
+		return nil, ErrInvalidToken
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(activation.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var active bool
+	if err := tx.QueryRow(QueryReadUserActive, user_id).Scan(&active); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !active {
+		return nil, ErrInactive
+	}
+
+	// The usedAt condition makes the token single use even with concurrent
+	// activations
+	res, err := tx.Exec(QueryUseInvitation, time.Now().Format(time.RFC3339), id)
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected == 0 {
+		return nil, ErrInvalidToken
+	}
+	if _, err := tx.Exec(QuerySetPassword, string(hashed), user_id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetUserById(user_id)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+	"go-pentview/i18n"
+	"time"
+)
+
+const tableNotifications = "notifications"
+
+var (
+	QueryCreateNotification = fmt.Sprintf("INSERT INTO %s(user_id_fk, message, createdAt) values(?,?,?)", tableNotifications)
+	QueryReadNotifications  = fmt.Sprintf("SELECT * FROM %s WHERE user_id_fk = ? ORDER BY notification_id DESC", tableNotifications)
+	QueryReadNotification   = fmt.Sprintf("UPDATE %s SET read = 1 WHERE notification_id = ? AND user_id_fk = ?", tableNotifications)
+	QueryReadAdminIds       = fmt.Sprintf("SELECT u.user_id FROM %s u JOIN %s r ON u.role_id_fk = r.role_id WHERE r.name = 'ADMIN' AND u.active = 1", tableUsers, tableRoles)
+)
+
+type Notification struct {
+	NotificationID int64  `json:"_id"`
+	UserID         int64  `json:"user"`
+	Message        string `json:"message"`
+	Read           bool   `json:"read"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+func (r *SQLiteRepository) CreateNotification(user_id int64, message string) (*Notification, error) {
+	notification := Notification{UserID: user_id, Message: message, CreatedAt: time.Now().Format(time.RFC3339)}
+	res, err := r.db.Exec(QueryCreateNotification, notification.UserID, notification.Message, notification.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	notification.NotificationID = id
+
+	return &notification, nil
+}
+
+// Notify sends the user the message of key in the i18n catalog, formatted with
+// args in the language of the user.
+func (r *SQLiteRepository) Notify(user_id int64, key string, args ...any) error {
+	lang, err := r.GetLanguage(user_id)
+	if err != nil {
+		return err
+	}
+	_, err = r.CreateNotification(user_id, i18n.T(lang, key, args...))
+	return err
+}
+
+// NotifyAdmins sends the message of key, like Notify, to every active user
+// holding the ADMIN role.
+func (r *SQLiteRepository) NotifyAdmins(key string, args ...any) error {
+	rows, err := r.db.Query(QueryReadAdminIds)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := r.Notify(id, key, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) AllNotifications(user_id int64) ([]Notification, error) {
+	rows, err := r.db.Query(QueryReadNotifications, user_id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []Notification
+	for rows.Next() {
+		var notification Notification
+		if err := rows.Scan(&notification.NotificationID, &notification.UserID, &notification.Message, &notification.Read, &notification.CreatedAt); err != nil {
+			return nil, err
+		}
+		all = append(all, notification)
+	}
+	return all, nil
+}
+
+func (r *SQLiteRepository) ReadNotification(id int64, user_id int64) error {
+	res, err := r.db.Exec(QueryReadNotification, id, user_id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUpdateFailed
+	}
+
+	return err
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+const tableOutbox = "outbox"
+
+// MaxMailAttempts is the number of deliveries tried before a mail is left as
+// failed.
+const MaxMailAttempts = 5
+
+var (
+	QueryQueueMail   = fmt.Sprintf("INSERT INTO %s(recipient, subject, body, createdAt) values(?,?,?,?)", tableOutbox)
+	QueryPendingMail = fmt.Sprintf("SELECT mail_id, recipient, subject, body, attempts FROM %s WHERE sentAt = '' AND attempts < ? ORDER BY mail_id LIMIT ?", tableOutbox)
+	QueryMailSent    = fmt.Sprintf("UPDATE %s SET sentAt = ?, attempts = attempts + 1, error = '', body = '' WHERE mail_id = ?", tableOutbox)
+	QueryMailFailed  = fmt.Sprintf("UPDATE %s SET attempts = attempts + 1, error = ?, body = CASE WHEN attempts + 1 >= ? THEN '' ELSE body END WHERE mail_id = ?", tableOutbox)
+)
+
+type Mail struct {
+	MailID    int64
+	Recipient string
+	Subject   string
+	Body      string
+	Attempts  int
+}
+
+func (r *SQLiteRepository) QueueMail(recipient string, subject string, body string) error {
+	_, err := r.db.Exec(QueryQueueMail, recipient, subject, body, time.Now().Format(time.RFC3339))
+	return err
+}
+
+func (r *SQLiteRepository) PendingMail(limit int) ([]Mail, error) {
+	rows, err := r.db.Query(QueryPendingMail, MaxMailAttempts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []Mail
+	for rows.Next() {
+		var mail Mail
+		if err := rows.Scan(&mail.MailID, &mail.Recipient, &mail.Subject, &mail.Body, &mail.Attempts); err != nil {
+			return nil, err
+		}
+		all = append(all, mail)
+	}
+	return all, nil
+}
+
+// MarkMailSent records the delivery of the mail and clears its body, which may
+// carry secrets like activation links that must not outlive the delivery.
+func (r *SQLiteRepository) MarkMailSent(id int64) error {
+	_, err := r.db.Exec(QueryMailSent, time.Now().Format(time.RFC3339), id)
+	return err
+}
+
+// MarkMailFailed records a failed delivery of the mail, clearing its body like
+// MarkMailSent once no more deliveries will be tried.
+func (r *SQLiteRepository) MarkMailFailed(id int64, cause error) error {
+	_, err := r.db.Exec(QueryMailFailed, cause.Error(), MaxMailAttempts, id)
+	return err
+}
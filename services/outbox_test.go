@@ -0,0 +1,35 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMailBodyCleared(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	for i := 0; i < 2; i++ {
+		if err := repo.QueueMail("test@example.com", "Activate", "https://example.com/activate?token=secret"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pending, err := repo.PendingMail(10)
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("pending mail: got %v, %v", pending, err)
+	}
+	if err := repo.MarkMailSent(pending[0].MailID); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < MaxMailAttempts; i++ {
+		if err := repo.MarkMailFailed(pending[1].MailID, errors.New("unreachable")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stored int
+	if err := repo.db.QueryRow("SELECT COUNT(*) FROM outbox WHERE body <> ''").Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != 0 {
+		t.Errorf("got %d mails keeping their body after delivery, want 0", stored)
+	}
+}
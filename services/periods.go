@@ -0,0 +1,161 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const tablePayPeriods = "pay_periods"
+
+var ErrPeriodClosed = errors.New("pay period is closed")
+
+var (
+	QueryCreatePayPeriod      = fmt.Sprintf("INSERT INTO %s(frequency, start, end, createdAt) values(?,?,?,?)", tablePayPeriods)
+	QueryReadPayPeriods       = fmt.Sprintf("SELECT * FROM %s ORDER BY start", tablePayPeriods)
+	QueryReadPayPeriodById    = fmt.Sprintf("SELECT * FROM %s WHERE period_id = ?", tablePayPeriods)
+	QueryReadOverlappingCount = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE start <= ? AND end >= ?", tablePayPeriods)
+	QueryReadClosedCount      = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE closed = 1 AND start <= ? AND end >= ?", tablePayPeriods)
+	QueryClosePayPeriod       = fmt.Sprintf("UPDATE %s SET closed = 1, closedBy = ?, closedAt = ? WHERE period_id = ? AND closed = 0", tablePayPeriods)
+	QueryReopenPayPeriod      = fmt.Sprintf("UPDATE %s SET closed = 0, reopenedBy = ?, reopenedAt = ? WHERE period_id = ? AND closed = 1", tablePayPeriods)
+)
+
+const (
+	FrequencyWeekly   = "weekly"
+	FrequencyBiweekly = "biweekly"
+	FrequencyMonthly  = "monthly"
+)
+
+// PayPeriod is a range of days, both inclusive and formatted as 2006-01-02,
+// whose clockings can no longer change once it is closed.
+type PayPeriod struct {
+	PeriodID   int64  `json:"_id"`
+	Frequency  string `json:"frequency" validate:"required,oneof=weekly biweekly monthly"`
+	Start      string `json:"start" validate:"required,date"`
+	End        string `json:"end"`
+	Closed     bool   `json:"closed"`
+	ClosedBy   int64  `json:"closedBy"`
+	ClosedAt   string `json:"closedAt"`
+	ReopenedBy int64  `json:"reopenedBy"`
+	ReopenedAt string `json:"reopenedAt"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+func (r *SQLiteRepository) CreatePayPeriod(period PayPeriod) (*PayPeriod, error) {
+	start, err := time.Parse(time.DateOnly, period.Start)
+	if err != nil {
+		return nil, invalid("invalid_date", "start")
+	}
+	var end time.Time
+	switch period.Frequency {
+	case FrequencyWeekly:
+		end = start.AddDate(0, 0, 6)
+	case FrequencyBiweekly:
+		end = start.AddDate(0, 0, 13)
+	case FrequencyMonthly:
+		end = start.AddDate(0, 1, -1)
+	default:
+		return nil, invalid("invalid_frequency")
+	}
+	period.End = end.Format(time.DateOnly)
+
+	var overlapping int
+	if err := r.db.QueryRow(QueryReadOverlappingCount, period.End, period.Start).Scan(&overlapping); err != nil {
+		return nil, err
+	}
+	if overlapping > 0 {
+		return nil, invalid("period_overlap")
+	}
+
+	period.CreatedAt = time.Now().Format(time.RFC3339)
+	res, err := r.db.Exec(QueryCreatePayPeriod, period.Frequency, period.Start, period.End, period.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	period.PeriodID = id
+
+	return &period, nil
+}
+
+func (r *SQLiteRepository) AllPayPeriods() ([]PayPeriod, error) {
+	rows, err := r.db.Query(QueryReadPayPeriods)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []PayPeriod
+	for rows.Next() {
+		var period PayPeriod
+		if err := rows.Scan(&period.PeriodID, &period.Frequency, &period.Start, &period.End, &period.Closed, &period.ClosedBy, &period.ClosedAt, &period.ReopenedBy, &period.ReopenedAt, &period.CreatedAt); err != nil {
+			return nil, err
+		}
+		all = append(all, period)
+	}
+	return all, nil
+}
+
+func (r *SQLiteRepository) GetPayPeriodById(id int64) (*PayPeriod, error) {
+	row := r.db.QueryRow(QueryReadPayPeriodById, id)
+
+	var period PayPeriod
+	if err := row.Scan(&period.PeriodID, &period.Frequency, &period.Start, &period.End, &period.Closed, &period.ClosedBy, &period.ClosedAt, &period.ReopenedBy, &period.ReopenedAt, &period.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotExists
+		}
+		return nil, err
+	}
+	return &period, nil
+}
+
+func (r *SQLiteRepository) ClosePayPeriod(id int64, admin_id int64) (*PayPeriod, error) {
+	return r.setPayPeriodState(QueryClosePayPeriod, id, admin_id)
+}
+
+func (r *SQLiteRepository) ReopenPayPeriod(id int64, admin_id int64) (*PayPeriod, error) {
+	return r.setPayPeriodState(QueryReopenPayPeriod, id, admin_id)
+}
+
+func (r *SQLiteRepository) setPayPeriodState(query string, id int64, admin_id int64) (*PayPeriod, error) {
+	res, err := r.db.Exec(query, admin_id, time.Now().Format(time.RFC3339), id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrUpdateFailed
+	}
+
+	return r.GetPayPeriodById(id)
+}
+
+// checkPeriodOpen returns ErrPeriodClosed when the day of date falls inside a
+// closed pay period. Dates that are not RFC3339 cannot be placed in a period and
+// are rejected.
+func (r *SQLiteRepository) checkPeriodOpen(date string) error {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return invalid("invalid_datetime", "register")
+	}
+	day := t.Format(time.DateOnly)
+
+	var closed int
+	if err := r.db.QueryRow(QueryReadClosedCount, day, day).Scan(&closed); err != nil {
+		return err
+	}
+	if closed > 0 {
+		return ErrPeriodClosed
+	}
+	return nil
+}
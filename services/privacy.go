@@ -0,0 +1,246 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	QueryReadLedgerCreatedBy = fmt.Sprintf("SELECT * FROM %s WHERE createdBy = ? AND user_id_fk <> ? ORDER BY entry_id", tableLedger)
+	QueryReadInvitations     = fmt.Sprintf("SELECT invitation_id, expiresAt, usedAt, createdAt FROM %s WHERE user_id_fk = ? ORDER BY invitation_id", tableInvitations)
+	QueryAnonymizeUser       = fmt.Sprintf("UPDATE %s SET name = ?, last = '', email = ?, password = '', pfp = ?, code = '' WHERE user_id = ? AND active = 0", tableUsers)
+	QueryDeleteOutboxMail    = fmt.Sprintf("DELETE FROM %s WHERE recipient = ?", tableOutbox)
+	QueryReadOutboxMail      = fmt.Sprintf("SELECT subject, sentAt, createdAt FROM %s WHERE recipient = ? ORDER BY mail_id", tableOutbox)
+)
+
+// Actions of the audit entries of PersonalData.
+const (
+	AuditUserCreated       = "user_created"
+	AuditUserDeactivated   = "user_deactivated"
+	AuditInvitationCreated = "invitation_created"
+	AuditInvitationUsed    = "invitation_used"
+	AuditBalanceAdjusted   = "balance_adjusted"
+	AuditPeriodClosed      = "pay_period_closed"
+	AuditPeriodReopened    = "pay_period_reopened"
+	AuditMailQueued        = "mail_queued"
+	AuditMailSent          = "mail_sent"
+)
+
+// AuditEntry is an action recorded about the user, or by it. Actor is the user
+// who performed it, 0 when the records do not tell, and Subject the user it
+// was performed on.
+type AuditEntry struct {
+	At      string `json:"at"`
+	Action  string `json:"action"`
+	Actor   int64  `json:"actor"`
+	Subject int64  `json:"subject"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// InvitationRecord is an invitation as disclosed to its user, without token.
+type InvitationRecord struct {
+	InvitationID int64  `json:"_id"`
+	ExpiresAt    string `json:"expiresAt"`
+	UsedAt       string `json:"usedAt"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+// PersonalData is everything stored about a user. Adjustments and PayPeriods
+// are the actions the user performed as administrator. There is no separate
+// audit log: Audit lists, oldest first, the actions stamped on those records,
+// on the ledger and on the mails sent to the user.
+type PersonalData struct {
+	ExportedAt    string             `json:"exportedAt"`
+	User          User               `json:"user"`
+	Contracts     []Contract         `json:"contracts"`
+	Clockings     []Clocking         `json:"clockings"`
+	Entitlement   *Entitlement       `json:"entitlement"`
+	Ledger        []LedgerEntry      `json:"ledger"`
+	Notifications []Notification     `json:"notifications"`
+	Invitations   []InvitationRecord `json:"invitations"`
+	Adjustments   []LedgerEntry      `json:"adjustments"`
+	PayPeriods    []PayPeriod        `json:"payPeriods"`
+	Audit         []AuditEntry       `json:"audit"`
+}
+
+// GetPersonalData gathers every record about the user, to answer a data
+// access request.
+func (r *SQLiteRepository) GetPersonalData(user_id int64) (*PersonalData, error) {
+	user, err := r.GetUserById(user_id)
+	if err != nil {
+		return nil, err
+	}
+	data := PersonalData{ExportedAt: time.Now().Format(time.RFC3339), User: *user, Invitations: []InvitationRecord{}, Adjustments: []LedgerEntry{}, PayPeriods: []PayPeriod{}}
+
+	if data.Contracts, err = r.AllContracts(user_id); err != nil {
+		return nil, err
+	}
+	if data.Clockings, err = r.AllClockings(user_id); err != nil {
+		return nil, err
+	}
+	if data.Entitlement, err = r.GetEntitlementByUser(user_id); err != nil && !errors.Is(err, ErrNotExists) {
+		return nil, err
+	}
+	if data.Ledger, err = r.AllLedgerEntries(user_id); err != nil {
+		return nil, err
+	}
+	if data.Notifications, err = r.AllNotifications(user_id); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(QueryReadInvitations, user_id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var invitation InvitationRecord
+		if err := rows.Scan(&invitation.InvitationID, &invitation.ExpiresAt, &invitation.UsedAt, &invitation.CreatedAt); err != nil {
+			return nil, err
+		}
+		data.Invitations = append(data.Invitations, invitation)
+	}
+
+	adjustments, err := r.db.Query(QueryReadLedgerCreatedBy, user_id, user_id)
+	if err != nil {
+		return nil, err
+	}
+	defer adjustments.Close()
+	for adjustments.Next() {
+		var entry LedgerEntry
+		if err := adjustments.Scan(&entry.EntryID, &entry.UserID, &entry.Amount, &entry.Kind, &entry.Period, &entry.Reason, &entry.CreatedBy, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		data.Adjustments = append(data.Adjustments, entry)
+	}
+
+	periods, err := r.AllPayPeriods()
+	if err != nil {
+		return nil, err
+	}
+	for _, period := range periods {
+		if period.ClosedBy == user_id || period.ReopenedBy == user_id {
+			data.PayPeriods = append(data.PayPeriods, period)
+		}
+	}
+	if data.Audit, err = r.audit(&data); err != nil {
+		return nil, err
+	}
+
+	// Empty lists are disclosed as such rather than as null
+	if data.Contracts == nil {
+		data.Contracts = []Contract{}
+	}
+	if data.Clockings == nil {
+		data.Clockings = []Clocking{}
+	}
+	if data.Ledger == nil {
+		data.Ledger = []LedgerEntry{}
+	}
+	if data.Notifications == nil {
+		data.Notifications = []Notification{}
+	}
+	return &data, nil
+}
+
+// audit gathers the audit entries of the records of data, which must be
+// loaded, and of the mails sent to the user, oldest first.
+func (r *SQLiteRepository) audit(data *PersonalData) ([]AuditEntry, error) {
+	user_id := data.User.UserID
+	audit := []AuditEntry{{At: data.User.CreatedAt, Action: AuditUserCreated, Subject: user_id}}
+	if data.User.DeactivatedAt != "" {
+		audit = append(audit, AuditEntry{At: data.User.DeactivatedAt, Action: AuditUserDeactivated, Subject: user_id})
+	}
+	for _, invitation := range data.Invitations {
+		audit = append(audit, AuditEntry{At: invitation.CreatedAt, Action: AuditInvitationCreated, Subject: user_id})
+		if invitation.UsedAt != "" {
+			audit = append(audit, AuditEntry{At: invitation.UsedAt, Action: AuditInvitationUsed, Actor: user_id, Subject: user_id})
+		}
+	}
+	// Entries created by no one are written by the entitlement accrual job,
+	// AccrueBalances: its accruals, year closes and expiries
+	for _, entries := range [][]LedgerEntry{data.Ledger, data.Adjustments} {
+		for _, entry := range entries {
+			if entry.CreatedBy != 0 {
+				audit = append(audit, AuditEntry{At: entry.CreatedAt, Action: AuditBalanceAdjusted, Actor: entry.CreatedBy, Subject: entry.UserID, Detail: entry.Reason})
+			}
+		}
+	}
+	for _, period := range data.PayPeriods {
+		if period.ClosedBy == user_id {
+			audit = append(audit, AuditEntry{At: period.ClosedAt, Action: AuditPeriodClosed, Actor: user_id, Detail: period.Start})
+		}
+		if period.ReopenedBy == user_id {
+			audit = append(audit, AuditEntry{At: period.ReopenedAt, Action: AuditPeriodReopened, Actor: user_id, Detail: period.Start})
+		}
+	}
+
+	rows, err := r.db.Query(QueryReadOutboxMail, data.User.Email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var subject, sentAt, createdAt string
+		if err := rows.Scan(&subject, &sentAt, &createdAt); err != nil {
+			return nil, err
+		}
+		audit = append(audit, AuditEntry{At: createdAt, Action: AuditMailQueued, Subject: user_id, Detail: subject})
+		if sentAt != "" {
+			audit = append(audit, AuditEntry{At: sentAt, Action: AuditMailSent, Subject: user_id, Detail: subject})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(audit, func(i, j int) bool { return audit[i].At < audit[j].At })
+	return audit, nil
+}
+
+// AnonymizeUser scrubs the personal fields of a deactivated user to answer an
+// erasure request. Clockings, contracts and the balance ledger are kept under
+// the same id, as labor law requires, but can no longer be tied to a person.
+// Returns the user as it was, so the caller can dispose of its profile image.
+func (r *SQLiteRepository) AnonymizeUser(user_id int64) (*User, error) {
+	user, err := r.GetUserById(user_id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Active {
+		return nil, invalid("anonymize_active")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(QueryAnonymizeUser, "Anonymized", fmt.Sprintf("anonymized-%d@invalid", user_id), DefaultPFP, user_id)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, ErrUpdateFailed
+	}
+	for _, table := range []string{tableNotifications, tableInvitations, tableFieldValues} {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE user_id_fk = ?", table), user_id); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(QueryDeleteOutboxMail, user.Email); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
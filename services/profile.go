@@ -4,42 +4,63 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
 )
 
 var (
 	QueryReadProfile   = fmt.Sprintf("SELECT * FROM %s u JOIN %s r ON u.role_id_fk = r.role_id WHERE user_id = ?", tableUsers, tableRoles)
-	QueryUpdateProfile = fmt.Sprintf("UPDATE %s SET name = ?, last = ?, email = ? WHERE user_id = ?", tableUsers)
+	QueryUpdateProfile = fmt.Sprintf("UPDATE %s SET name = ?, last = ?, email = ?, lang = ? WHERE user_id = ?", tableUsers)
+	QueryReadLanguage  = fmt.Sprintf("SELECT lang FROM %s WHERE user_id = ?", tableUsers)
+	QueryReadSession   = fmt.Sprintf("SELECT active, lang FROM %s WHERE user_id = ?", tableUsers)
+	QueryReadPFP       = fmt.Sprintf("SELECT pfp FROM %s WHERE user_id = ?", tableUsers)
+	QueryUpdatePFP     = fmt.Sprintf("UPDATE %s SET pfp = ? WHERE user_id = ?", tableUsers)
+	QueryReservePFP    = fmt.Sprintf("INSERT INTO %[1]s(pfp, createdAt) SELECT ?1, ?2 WHERE NOT EXISTS (SELECT 1 FROM %[1]s WHERE pfp = ?1 AND removing = 1)", tablePFPClaims)
+	QueryClaimRemoval  = fmt.Sprintf("INSERT INTO %[1]s(pfp, removing, createdAt) SELECT ?1, 1, ?2 WHERE NOT EXISTS (SELECT 1 FROM %[2]s WHERE pfp = ?1) AND NOT EXISTS (SELECT 1 FROM %[1]s WHERE pfp = ?1)", tablePFPClaims, tableUsers)
+	QueryReleasePFP    = fmt.Sprintf("DELETE FROM %s WHERE claim_id = ?", tablePFPClaims)
 )
 
+// tablePFPClaims holds the profile images being uploaded or removed. Images
+// are stored by content, so an upload may write the same blobs a removal is
+// deleting; claims keep them apart across every server sharing the database.
+const tablePFPClaims = "pfp_claims"
+
 type PutProfile struct {
-	Name  string `json:"firstName"`
-	Last  string `json:"lastName"`
-	Email string `json:"email"`
+	Name  string `json:"firstName" validate:"required,max=100"`
+	Last  string `json:"lastName" validate:"max=100"`
+	Email string `json:"email" validate:"required,email,max=254"`
+	// Lang is the preferred language, "" to follow Accept-Language. It is
+	// kept when omitted.
+	Lang *string `json:"language" validate:"omitempty,oneof=es en"`
 }
 
 func (r *SQLiteRepository) GetProfileById(id int64) (*User, error) {
 	row := r.db.QueryRow(QueryReadProfile, id)
 
-	var (
-		user User
-		fk   string
-	)
-	if err := row.Scan(&user.UserID, &user.Name, &user.Last, &user.Email, &user.Password, &user.PFP, &user.CreatedAt, &fk, &user.Role.RoleID, &user.Role.Name, &user.Role.CreatedAt); err != nil {
+	user, err := scanUser(row)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotExists
 		}
 		return nil, err
 	}
 	user.Password = ""
-	return &user, nil
+	return user, r.loadFields(user)
 }
 
 func (r *SQLiteRepository) UpdateProfile(id int64, updated User) (*User, error) {
 	if id == 0 {
-		return nil, errors.New("invalid updated ID")
+		return nil, invalid("invalid_id")
 	}
-	res, err := r.db.Exec(QueryUpdateProfile, updated.Name, updated.Last, updated.Email, id)
+	res, err := r.db.Exec(QueryUpdateProfile, updated.Name, updated.Last, updated.Email, updated.Lang, id)
 	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) {
+			if errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+				return nil, ErrDuplicate
+			}
+		}
 		return nil, err
 	}
 
@@ -54,3 +75,93 @@ func (r *SQLiteRepository) UpdateProfile(id int64, updated User) (*User, error)
 
 	return &updated, nil
 }
+
+// ReservePFP claims pfp for an upload until it is referenced by a user, so it
+// is not removed meanwhile, and returns the id that releases the claim. It
+// fails with ErrPFPRemoving while the image is being removed.
+func (r *SQLiteRepository) ReservePFP(pfp string) (int64, error) {
+	res, err := r.db.Exec(QueryReservePFP, pfp, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, ErrPFPRemoving
+	}
+	return res.LastInsertId()
+}
+
+// ClaimPFPRemoval claims pfp for its removal when no user has it and no upload
+// or other removal claimed it. Images are stored by content, so several users
+// may share the same one. It returns the id that releases the claim once the
+// image is removed, and false when the image must be kept.
+func (r *SQLiteRepository) ClaimPFPRemoval(pfp string) (int64, bool, error) {
+	res, err := r.db.Exec(QueryClaimRemoval, pfp, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return 0, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return 0, false, err
+	}
+	id, err := res.LastInsertId()
+	return id, err == nil, err
+}
+
+// ReleasePFP drops a claim of ReservePFP or ClaimPFPRemoval.
+func (r *SQLiteRepository) ReleasePFP(claim int64) error {
+	_, err := r.db.Exec(QueryReleasePFP, claim)
+	return err
+}
+
+// SetPFP changes the profile image of the user and returns the previous one,
+// so the caller can dispose of it.
+func (r *SQLiteRepository) SetPFP(id int64, pfp string) (string, error) {
+	var old string
+	if err := r.db.QueryRow(QueryReadPFP, id).Scan(&old); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotExists
+		}
+		return "", err
+	}
+	res, err := r.db.Exec(QueryUpdatePFP, pfp, id)
+	if err != nil {
+		return "", err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if rowsAffected == 0 {
+		return "", ErrUpdateFailed
+	}
+	return old, nil
+}
+
+// GetLanguage returns the language the user chose for the API, or "".
+func (r *SQLiteRepository) GetLanguage(id int64) (string, error) {
+	var lang string
+	if err := r.db.QueryRow(QueryReadLanguage, id).Scan(&lang); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotExists
+		}
+		return "", err
+	}
+	return lang, nil
+}
+
+// GetSession returns whether the user is active and the language it chose,
+// what every request needs to know of the user of its token.
+func (r *SQLiteRepository) GetSession(id int64) (bool, string, error) {
+	var (
+		active bool
+		lang   string
+	)
+	if err := r.db.QueryRow(QueryReadSession, id).Scan(&active, &lang); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, "", ErrNotExists
+		}
+		return false, "", err
+	}
+	return active, lang, nil
+}
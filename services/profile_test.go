@@ -0,0 +1,45 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPFPClaims(t *testing.T) {
+	repo, user := newTestRepository(t)
+	const pfp = "upload/shared.png"
+
+	reserved, err := repo.ReservePFP(pfp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := repo.ClaimPFPRemoval(pfp); err != nil || ok {
+		t.Errorf("removal of a reserved image: got %v, %v, want it kept", ok, err)
+	}
+	if _, err := repo.SetPFP(user.UserID, pfp); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ReleasePFP(reserved); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := repo.ClaimPFPRemoval(pfp); err != nil || ok {
+		t.Errorf("removal of a referenced image: got %v, %v, want it kept", ok, err)
+	}
+
+	if _, err := repo.SetPFP(user.UserID, DefaultPFP); err != nil {
+		t.Fatal(err)
+	}
+	removing, ok, err := repo.ClaimPFPRemoval(pfp)
+	if err != nil || !ok {
+		t.Fatalf("removal of an unused image: got %v, %v", ok, err)
+	}
+	if _, err := repo.ReservePFP(pfp); !errors.Is(err, ErrPFPRemoving) {
+		t.Errorf("upload of an image being removed: got %v, want ErrPFPRemoving", err)
+	}
+	if err := repo.ReleasePFP(removing); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ReservePFP(pfp); err != nil {
+		t.Errorf("upload of a removed image: got %v", err)
+	}
+}
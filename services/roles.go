@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/mattn/go-sqlite3"
@@ -14,17 +15,34 @@ const tableRoles = "roles"
 var (
 	QueryCreateRole     = fmt.Sprintf("INSERT INTO %s(name, createdAt) values(UPPER(?),?)", tableRoles)
 	QueryReadRoles      = fmt.Sprintf("SELECT * FROM %s", tableRoles)
-	QueryReadRoleByName = fmt.Sprintf("SELECT * FROM %s WHERE name = ?", tableRoles)
+	QueryReadRoleById   = fmt.Sprintf("SELECT * FROM %s WHERE role_id = ?", tableRoles)
+	QueryReadRoleByName = fmt.Sprintf("SELECT * FROM %s WHERE name = UPPER(?)", tableRoles)
 	QueryUpdateRole     = fmt.Sprintf("UPDATE %s SET name = UPPER(?) WHERE role_id = ?", tableRoles)
 	QueryDeleteRole     = fmt.Sprintf("DELETE FROM %s WHERE role_id = ?", tableRoles)
+	QueryReadRoleUsage  = fmt.Sprintf(`SELECT r.*, COUNT(u.user_id), COALESCE(SUM(u.active), 0)
+		FROM %s r LEFT JOIN %s u ON u.role_id_fk = r.role_id GROUP BY r.role_id ORDER BY r.role_id`, tableRoles, tableUsers)
+	QueryCountRoleUsers    = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE role_id_fk = ?", tableUsers)
+	QueryReassignRoleUsers = fmt.Sprintf("UPDATE %s SET role_id_fk = ? WHERE role_id_fk = ?", tableUsers)
+	QueryDeleteRoleDefault = fmt.Sprintf("DELETE FROM %s WHERE role_id_fk = ? AND user_id_fk = 0", tableEntitlements)
 )
 
+// AdminRole is the name of the role granting administration rights.
+const AdminRole = "ADMIN"
+
 type Role struct {
 	RoleID    int64  `json:"_id"`
-	Name      string `json:"name"`
+	Name      string `json:"name" validate:"required,max=50"`
 	CreatedAt string `json:"createdAt"`
 }
 
+// RoleUsage is a role with how many users, and of them how many active, have
+// it assigned.
+type RoleUsage struct {
+	Role
+	Users       int `json:"users"`
+	ActiveUsers int `json:"activeUsers"`
+}
+
 func (r *SQLiteRepository) CreateRole(role Role) (*Role, error) {
 	res, err := r.db.Exec(QueryCreateRole, role.Name, time.Now().Format(time.RFC3339))
 	if err != nil {
@@ -64,11 +82,42 @@ func (r *SQLiteRepository) AllRoles() ([]Role, error) {
 	return all, nil
 }
 
+func (r *SQLiteRepository) AllRolesWithUsage() ([]RoleUsage, error) {
+	rows, err := r.db.Query(QueryReadRoleUsage)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []RoleUsage
+	for rows.Next() {
+		var role RoleUsage
+		if err := rows.Scan(&role.RoleID, &role.Name, &role.CreatedAt, &role.Users, &role.ActiveUsers); err != nil {
+			return nil, err
+		}
+		all = append(all, role)
+	}
+	return all, nil
+}
+
+func (r *SQLiteRepository) GetRoleById(id int64) (*Role, error) {
+	row := r.db.QueryRow(QueryReadRoleById, id)
+
+	var role Role
+	if err := row.Scan(&role.RoleID, &role.Name, &role.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotExists
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
 func (r *SQLiteRepository) GetRoleByName(name string) (*Role, error) {
 	row := r.db.QueryRow(QueryReadRoleByName, name)
 
 	var role Role
-	if err := row.Scan(&role.RoleID, &role.Name); err != nil {
+	if err := row.Scan(&role.RoleID, &role.Name, &role.CreatedAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotExists
 		}
@@ -77,12 +126,30 @@ func (r *SQLiteRepository) GetRoleByName(name string) (*Role, error) {
 	return &role, nil
 }
 
+// UpdateRole renames the role. The ADMIN role grants administration rights by
+// its name, so it cannot be renamed nor can another role take its name.
 func (r *SQLiteRepository) UpdateRole(id int64, updated Role) (*Role, error) {
 	if id == 0 {
-		return nil, errors.New("invalid updated ID")
+		return nil, invalid("invalid_id")
+	}
+	if strings.TrimSpace(updated.Name) == "" {
+		return nil, invalid("required", "name")
+	}
+	current, err := r.GetRoleById(id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Name == AdminRole || strings.EqualFold(updated.Name, AdminRole) {
+		return nil, invalid("admin_not_renamable", AdminRole)
 	}
 	res, err := r.db.Exec(QueryUpdateRole, updated.Name, id)
 	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) {
+			if errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+				return nil, ErrDuplicate
+			}
+		}
 		return nil, err
 	}
 
@@ -95,11 +162,53 @@ func (r *SQLiteRepository) UpdateRole(id int64, updated Role) (*Role, error) {
 		return nil, ErrUpdateFailed
 	}
 
-	return &updated, nil
+	return r.GetRoleById(id)
 }
 
-func (r *SQLiteRepository) DeleteRole(id int64) error {
-	res, err := r.db.Exec(QueryDeleteRole, id)
+// DeleteRole deletes a role together with its default entitlement. A role
+// still assigned to users is only deleted when reassign names another role to
+// move them to, otherwise ErrRoleInUse is returned. The ADMIN role cannot be
+// deleted.
+func (r *SQLiteRepository) DeleteRole(id int64, reassign int64) error {
+	role, err := r.GetRoleById(id)
+	if err != nil {
+		return err
+	}
+	if role.Name == AdminRole {
+		return invalid("admin_not_deletable", AdminRole)
+	}
+	if reassign != 0 {
+		if reassign == id {
+			return invalid("reassign_to_deleted")
+		}
+		if _, err := r.GetRoleById(reassign); err != nil {
+			return invalid("role_not_found", reassign)
+		}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if reassign != 0 {
+		if _, err := tx.Exec(QueryReassignRoleUsers, reassign, id); err != nil {
+			return err
+		}
+	} else {
+		var users int
+		if err := tx.QueryRow(QueryCountRoleUsers, id).Scan(&users); err != nil {
+			return err
+		}
+		if users > 0 {
+			return ErrRoleInUse
+		}
+	}
+	if _, err := tx.Exec(QueryDeleteRoleDefault, id); err != nil {
+		return err
+	}
+	res, err := tx.Exec(QueryDeleteRole, id)
 	if err != nil {
 		return err
 	}
@@ -113,5 +222,5 @@ func (r *SQLiteRepository) DeleteRole(id int64) error {
 		return ErrDeleteFailed
 	}
 
-	return err
+	return tx.Commit()
 }
@@ -3,6 +3,7 @@ package services
 import (
 	"database/sql"
 	"errors"
+	"go-pentview/i18n"
 )
 
 var (
@@ -10,8 +11,28 @@ var (
 	ErrNotExists    = errors.New("row not exists")
 	ErrUpdateFailed = errors.New("update failed")
 	ErrDeleteFailed = errors.New("delete failed")
+	ErrInactive     = errors.New("user is deactivated")
+	ErrLastAdmin    = errors.New("the last administrator cannot lose its role or be deactivated")
+	ErrRoleInUse    = errors.New("role is assigned to users")
+	ErrPFPRemoving  = errors.New("profile image is being removed")
 )
 
+// Invalid is a request rejected for breaking a rule of the services, which is
+// the fault of the client rather than of the server. Code names the rule and
+// its message in the i18n catalog, which Args fill in.
+type Invalid struct {
+	Code string
+	Args []any
+}
+
+func (e *Invalid) Error() string {
+	return i18n.T("en", e.Code, e.Args...)
+}
+
+func invalid(code string, args ...any) error {
+	return &Invalid{Code: code, Args: args}
+}
+
 type SQLiteRepository struct {
 	db *sql.DB
 }
@@ -38,6 +59,12 @@ func (r *SQLiteRepository) Migrate() error {
 			password TEXT NOT NULL,
 			pfp TEXT NOT NULL,
 			createdAt TEXT NOT NULL,
+			code TEXT NOT NULL DEFAULT '',
+			active INTEGER NOT NULL DEFAULT 1,
+			deactivatedAt TEXT NOT NULL DEFAULT '',
+			team_id_fk INTEGER NOT NULL DEFAULT 0,
+			manager_id_fk INTEGER NOT NULL DEFAULT 0,
+			lang TEXT NOT NULL DEFAULT '',
 			role_id_fk INTEGER,
 			FOREIGN KEY (role_id_fk)
 				REFERENCES roles (role_id)
@@ -48,6 +75,137 @@ func (r *SQLiteRepository) Migrate() error {
 			type TEXT NOT NULL,
 			date TEXT NOT NULL,
 			user_id_fk INTEGER,
+			review INTEGER NOT NULL DEFAULT 0,
+			FOREIGN KEY (user_id_fk)
+				REFERENCES users (user_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS entitlements (
+			entitlement_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id_fk INTEGER NOT NULL DEFAULT 0,
+			role_id_fk INTEGER NOT NULL DEFAULT 0,
+			annual REAL NOT NULL,
+			carryOverLimit REAL NOT NULL,
+			carryOverExpiry INTEGER NOT NULL,
+			createdAt TEXT NOT NULL,
+			UNIQUE (user_id_fk, role_id_fk)
+		);
+
+		CREATE TABLE IF NOT EXISTS ledger (
+			entry_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id_fk INTEGER NOT NULL,
+			amount REAL NOT NULL,
+			kind TEXT NOT NULL,
+			period TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			createdBy INTEGER NOT NULL DEFAULT 0,
+			createdAt TEXT NOT NULL,
+			FOREIGN KEY (user_id_fk)
+				REFERENCES users (user_id)
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS ledger_period
+			ON ledger (user_id_fk, kind, period) WHERE kind <> 'adjustment';
+
+		CREATE TABLE IF NOT EXISTS notifications (
+			notification_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id_fk INTEGER NOT NULL,
+			message TEXT NOT NULL,
+			read INTEGER NOT NULL DEFAULT 0,
+			createdAt TEXT NOT NULL,
+			FOREIGN KEY (user_id_fk)
+				REFERENCES users (user_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS pay_periods (
+			period_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			frequency TEXT NOT NULL,
+			start TEXT NOT NULL,
+			end TEXT NOT NULL,
+			closed INTEGER NOT NULL DEFAULT 0,
+			closedBy INTEGER NOT NULL DEFAULT 0,
+			closedAt TEXT NOT NULL DEFAULT '',
+			reopenedBy INTEGER NOT NULL DEFAULT 0,
+			reopenedAt TEXT NOT NULL DEFAULT '',
+			createdAt TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS invitations (
+			invitation_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id_fk INTEGER NOT NULL,
+			token TEXT NOT NULL UNIQUE,
+			expiresAt TEXT NOT NULL,
+			usedAt TEXT NOT NULL DEFAULT '',
+			createdAt TEXT NOT NULL,
+			FOREIGN KEY (user_id_fk)
+				REFERENCES users (user_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS outbox (
+			mail_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recipient TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			body TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			sentAt TEXT NOT NULL DEFAULT '',
+			createdAt TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS pfp_claims (
+			claim_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			pfp TEXT NOT NULL,
+			removing INTEGER NOT NULL DEFAULT 0,
+			createdAt TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS departments (
+			department_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			createdAt TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS teams (
+			team_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			department_id_fk INTEGER NOT NULL,
+			createdAt TEXT NOT NULL,
+			UNIQUE (department_id_fk, name),
+			FOREIGN KEY (department_id_fk)
+				REFERENCES departments (department_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS fields (
+			field_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			key TEXT NOT NULL UNIQUE,
+			label TEXT NOT NULL,
+			type TEXT NOT NULL,
+			required INTEGER NOT NULL DEFAULT 0,
+			options TEXT NOT NULL DEFAULT 'null',
+			pattern TEXT NOT NULL DEFAULT '',
+			createdAt TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS field_values (
+			user_id_fk INTEGER NOT NULL,
+			field_id_fk INTEGER NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY (user_id_fk, field_id_fk),
+			FOREIGN KEY (user_id_fk)
+				REFERENCES users (user_id),
+			FOREIGN KEY (field_id_fk)
+				REFERENCES fields (field_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS contracts (
+			contract_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id_fk INTEGER NOT NULL,
+			start TEXT NOT NULL,
+			end TEXT NOT NULL DEFAULT '',
+			weeklyHours REAL NOT NULL,
+			workDays INTEGER NOT NULL DEFAULT 5,
+			overtime INTEGER NOT NULL DEFAULT 0,
+			createdAt TEXT NOT NULL,
 			FOREIGN KEY (user_id_fk)
 				REFERENCES users (user_id)
 		);`
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var QueryReadClockingRows = fmt.Sprintf(`SELECT c.clocking_id, c.type, c.date, c.review, u.user_id, u.name, u.last, u.email, u.code, r.role_id, r.name
+	FROM %s c JOIN %s u ON c.user_id_fk = u.user_id JOIN %s r ON u.role_id_fk = r.role_id`, tableClockings, tableUsers, tableRoles)
+
+// ClockingFilter narrows exports and timesheets. From and To are inclusive days
+// formatted as 2006-01-02 in the location of the request, zero values are not
+// applied. ManagerID keeps only the direct and indirect reports of a manager.
+type ClockingFilter struct {
+	From      string
+	To        string
+	UserID    int64
+	RoleID    int64
+	TeamID    int64
+	ManagerID int64
+}
+
+// ClockingRow is a clocking joined with the user that registered it.
+type ClockingRow struct {
+	Clocking
+	Time time.Time
+	User User
+}
+
+// TimesheetDay sums the worked hours of a user in a single day. Intervals are
+// attributed to the day of their "in". Open is set when the day ends with an
+// "in" that has no matching "out". Contract is the one in force that day, if
+// any, with the Expected hours and the resulting Balance.
+type TimesheetDay struct {
+	User      User
+	Day       string
+	Hours     float64
+	Intervals int
+	Open      bool
+	Contract  *Contract
+	Expected  float64
+	Balance   float64
+}
+
+func (f ClockingFilter) query() (string, []any, error) {
+	var (
+		where []string
+		args  []any
+	)
+	// Days are compared in the location of the request, so the bounds of the
+	// query are widened one day each side and refined once the date is parsed
+	if f.From != "" {
+		from, err := time.Parse(time.DateOnly, f.From)
+		if err != nil {
+			return "", nil, invalid("invalid_date", "from")
+		}
+		where = append(where, "substr(c.date, 1, 10) >= ?")
+		args = append(args, from.AddDate(0, 0, -1).Format(time.DateOnly))
+	}
+	if f.To != "" {
+		to, err := time.Parse(time.DateOnly, f.To)
+		if err != nil {
+			return "", nil, invalid("invalid_date", "to")
+		}
+		where = append(where, "substr(c.date, 1, 10) <= ?")
+		args = append(args, to.AddDate(0, 0, 1).Format(time.DateOnly))
+	}
+	if f.UserID != 0 {
+		where = append(where, "u.user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if f.RoleID != 0 {
+		where = append(where, "r.role_id = ?")
+		args = append(args, f.RoleID)
+	}
+	if f.TeamID != 0 {
+		where = append(where, "u.team_id_fk = ?")
+		args = append(args, f.TeamID)
+	}
+	if f.ManagerID != 0 {
+		where = append(where, fmt.Sprintf("u.user_id IN (%s)", QueryReadReportIds))
+		args = append(args, f.ManagerID)
+	}
+
+	query := QueryReadClockingRows
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	return query + " ORDER BY u.user_id, c.clocking_id", args, nil
+}
+
+func (f ClockingFilter) contains(day string) bool {
+	return (f.From == "" || day >= f.From) && (f.To == "" || day <= f.To)
+}
+
+// EachClocking streams the clockings matching filter to fn ordered by user and
+// registration, without loading the whole result in memory. Clockings whose
+// date cannot be parsed are skipped when the filter has a date range. The
+// "out" closing a shift that starts on the last day of the range is kept,
+// although it falls on the day after.
+func (r *SQLiteRepository) EachClocking(filter ClockingFilter, loc *time.Location, fn func(ClockingRow) error) error {
+	query, args, err := filter.query()
+	if err != nil {
+		return err
+	}
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// Rows come ordered by user, so its custom fields are loaded once per user
+	var (
+		fields   map[string]string
+		previous int64
+		// open is the user whose last clocking passed on to fn is an "in"
+		open int64
+	)
+	for rows.Next() {
+		var row ClockingRow
+		if err := rows.Scan(&row.ClockingID, &row.Type, &row.Date, &row.Review, &row.User.UserID, &row.User.Name, &row.User.Last, &row.User.Email, &row.User.EmployeeCode, &row.User.Role.RoleID, &row.User.Role.Name); err != nil {
+			return err
+		}
+		row.UserID = row.User.UserID
+		if fields == nil || row.UserID != previous {
+			if err := r.loadFields(&row.User); err != nil {
+				return err
+			}
+			fields, previous = row.User.Fields, row.UserID
+		}
+		row.User.Fields = fields
+
+		t, err := time.Parse(time.RFC3339, row.Date)
+		if err == nil {
+			row.Time = t.In(loc)
+		}
+		if filter.From != "" || filter.To != "" {
+			if err != nil {
+				continue
+			}
+			day := row.Time.Format(time.DateOnly)
+			closesShift := row.Type == "out" && open == row.UserID && filter.To != "" && day > filter.To
+			if !filter.contains(day) && !closesShift {
+				open = 0
+				continue
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+		open = 0
+		if row.Type == "in" {
+			open = row.UserID
+		}
+	}
+	return rows.Err()
+}
+
+// EachTimesheetDay streams the worked hours per user and day, in loc, of the
+// clockings matching filter.
+func (r *SQLiteRepository) EachTimesheetDay(filter ClockingFilter, loc *time.Location, fn func(TimesheetDay) error) error {
+	var (
+		current   *TimesheetDay
+		in        *ClockingRow
+		contracts []Contract
+		// contractsOf is the user contracts belongs to, as current is
+		// cleared by flush
+		contractsOf int64
+	)
+	flush := func() error {
+		if current == nil {
+			return nil
+		}
+		day := *current
+		current = nil
+		t, _ := time.Parse(time.DateOnly, day.Day)
+		day.Contract = contractOn(contracts, day.Day)
+		day.Expected, day.Balance = dayBalance(day.Contract, t, day.Hours)
+		return fn(day)
+	}
+
+	err := r.EachClocking(filter, loc, func(row ClockingRow) error {
+		if row.Time.IsZero() {
+			return nil
+		}
+		if in != nil && in.UserID != row.UserID {
+			in = nil
+		}
+
+		switch row.Type {
+		case "in":
+			day := row.Time.Format(time.DateOnly)
+			if current == nil || current.User.UserID != row.UserID || current.Day != day {
+				if err := flush(); err != nil {
+					return err
+				}
+				if contractsOf != row.UserID {
+					var err error
+					if contracts, err = r.AllContracts(row.UserID); err != nil {
+						return err
+					}
+					contractsOf = row.UserID
+				}
+				current = &TimesheetDay{User: row.User, Day: day}
+			}
+			current.Open = true
+			in = &row
+		case "out":
+			if in == nil || current == nil {
+				return nil
+			}
+			current.Hours += row.Time.Sub(in.Time).Hours()
+			current.Intervals++
+			current.Open = false
+			in = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mattn/go-sqlite3"
@@ -14,54 +15,102 @@ import (
 const tableUsers = "users"
 
 var (
-	QueryCreateUser   = fmt.Sprintf("INSERT INTO %s(name, last, email, password, pfp, createdAt, role_id_fk) values(?,?,?,?,?,?,?)", tableUsers)
-	QueryReadUser     = fmt.Sprintf("SELECT * FROM %s u JOIN %s r ON u.role_id_fk = r.role_id", tableUsers, tableRoles)
-	QueryReadUserById = fmt.Sprintf("SELECT * FROM %s u JOIN %s r ON u.role_id_fk = r.role_id WHERE user_id = ?", tableUsers, tableRoles)
-	QueryUpdateUser   = fmt.Sprintf("UPDATE %s SET name = ?, last = ?, email = ? WHERE user_id = ?", tableUsers)
-	QueryDeleteUser   = fmt.Sprintf("DELETE FROM %s WHERE user_id = ?", tableUsers)
+	QueryCreateUser        = fmt.Sprintf("INSERT INTO %s(name, last, email, password, pfp, createdAt, code, role_id_fk) values(?,?,?,?,?,?,?,?)", tableUsers)
+	QueryReadUser          = fmt.Sprintf("SELECT * FROM %s u JOIN %s r ON u.role_id_fk = r.role_id", tableUsers, tableRoles)
+	QueryReadUserById      = fmt.Sprintf("SELECT * FROM %s u JOIN %s r ON u.role_id_fk = r.role_id WHERE user_id = ?", tableUsers, tableRoles)
+	QueryUpdateUser        = fmt.Sprintf("UPDATE %s SET name = ?, last = ?, email = ?, code = CASE WHEN ? THEN code ELSE ? END, role_id_fk = ? WHERE user_id = ?", tableUsers)
+	QueryDeactivateUser    = fmt.Sprintf("UPDATE %s SET active = 0, deactivatedAt = ? WHERE user_id = ? AND active = 1", tableUsers)
+	QueryReactivateUser    = fmt.Sprintf("UPDATE %s SET active = 1, deactivatedAt = '' WHERE user_id = ? AND active = 0", tableUsers)
+	QueryReadUserActive    = fmt.Sprintf("SELECT active FROM %s WHERE user_id = ?", tableUsers)
+	QueryReadUserRole      = fmt.Sprintf("SELECT u.active, r.name FROM %s u JOIN %s r ON u.role_id_fk = r.role_id WHERE user_id = ?", tableUsers, tableRoles)
+	QueryCountActiveAdmins = fmt.Sprintf("SELECT COUNT(*) FROM %s u JOIN %s r ON u.role_id_fk = r.role_id WHERE r.name = 'ADMIN' AND u.active = 1", tableUsers, tableRoles)
+	QueryLastClocking      = fmt.Sprintf("SELECT COALESCE(MAX(date), '') FROM %s WHERE user_id_fk = ?", tableClockings)
 )
 
 type User struct {
-	UserID    int64  `json:"_id"`
-	Name      string `json:"firstName"`
-	Last      string `json:"lastName"`
-	Email     string `json:"email"`
-	Password  string `json:"password,omitempty"`
-	PFP       string `json:"profileImage"`
-	CreatedAt string `json:"createdAt"`
-	Role      Role   `json:"role"`
+	UserID        int64             `json:"_id"`
+	Name          string            `json:"firstName" validate:"required,max=100"`
+	Last          string            `json:"lastName" validate:"max=100"`
+	Email         string            `json:"email" validate:"required,email,max=254"`
+	Password      string            `json:"password,omitempty" validate:"omitempty,min=8,max=72"`
+	PFP           string            `json:"profileImage"`
+	CreatedAt     string            `json:"createdAt"`
+	EmployeeCode  string            `json:"employeeCode" validate:"max=10"`
+	Active        bool              `json:"active"`
+	DeactivatedAt string            `json:"deactivatedAt,omitempty"`
+	TeamID        int64             `json:"team"`
+	ManagerID     int64             `json:"manager"`
+	Lang          string            `json:"language"`
+	Fields        map[string]string `json:"fields"`
+	Role          Role              `json:"role"`
 }
 type UserToCreate struct {
-	UserID    int64  `json:"_id"`
-	Name      string `json:"firstName"`
-	Last      string `json:"lastName"`
-	Email     string `json:"email"`
-	Password  string `json:"password"`
-	PFP       string `json:"profileImage"`
-	CreatedAt string `json:"createdAt"`
-	Role      string `json:"role"`
+	UserID       int64  `json:"_id"`
+	Name         string `json:"firstName" validate:"required,max=100"`
+	Last         string `json:"lastName" validate:"max=100"`
+	Email        string `json:"email" validate:"required,email,max=254"`
+	Password     string `json:"password" validate:"omitempty,min=8,max=72"`
+	PFP          string `json:"profileImage"`
+	CreatedAt    string `json:"createdAt"`
+	EmployeeCode string `json:"employeeCode" validate:"max=10"`
+	Role         string `json:"role" validate:"required"`
+	// Fields are the values of the custom fields by key
+	Fields map[string]string `json:"fields,omitempty"`
 }
 
-func (r *SQLiteRepository) CreateUser(userToCreate UserToCreate) error {
+// CreateUser stores the user with its password hashed and its custom fields,
+// every required one with a value. A user created without password cannot
+// log in until it is activated through an invitation.
+func (r *SQLiteRepository) CreateUser(userToCreate UserToCreate) (*User, error) {
 	role_id, err := strconv.ParseInt(userToCreate.Role, 10, 64)
 	if err != nil {
-		return errors.New("role id must be string")
+		return nil, invalid("invalid_role")
 	}
+	fields, err := r.AllFields()
+	if err != nil {
+		return nil, err
+	}
+	values, err := newFieldValues(fields, userToCreate.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if userToCreate.Password != "" {
+		hashed, _ := bcrypt.GenerateFromPassword([]byte(userToCreate.Password), bcrypt.DefaultCost)
+		userToCreate.Password = string(hashed)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-	hashed, _ := bcrypt.GenerateFromPassword([]byte(userToCreate.Password), bcrypt.DefaultCost)
-	userToCreate.Password = string(hashed)
-	_, err = r.db.Exec(QueryCreateUser, userToCreate.Name, userToCreate.Last, userToCreate.Email, userToCreate.Password, userToCreate.PFP, time.Now().Format(time.RFC3339), role_id)
+	res, err := tx.Exec(QueryCreateUser, userToCreate.Name, userToCreate.Last, userToCreate.Email, userToCreate.Password, userToCreate.PFP, time.Now().Format(time.RFC3339), userToCreate.EmployeeCode, role_id)
 	if err != nil {
 		var sqliteErr sqlite3.Error
 		if errors.As(err, &sqliteErr) {
 			if errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
-				return ErrDuplicate
+				return nil, ErrDuplicate
 			}
 		}
-		return err
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	for field_id, value := range values {
+		if _, err := tx.Exec(QuerySetFieldValue, id, field_id, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return r.GetUserById(id)
 }
 
 func (r *SQLiteRepository) AllUsers() ([]User, error) {
@@ -73,39 +122,92 @@ func (r *SQLiteRepository) AllUsers() ([]User, error) {
 
 	var all []User
 	for rows.Next() {
-		var (
-			user User
-			fk   string
-		)
-		if err := rows.Scan(&user.UserID, &user.Name, &user.Last, &user.Email, &user.Password, &user.PFP, &user.CreatedAt, &fk, &user.Role.RoleID, &user.Role.Name, &user.Role.CreatedAt); err != nil {
+		user, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
 		user.Password = ""
-		all = append(all, user)
+		all = append(all, *user)
 	}
-	return all, nil
+	users := make([]*User, len(all))
+	for i := range all {
+		users[i] = &all[i]
+	}
+	return all, r.loadFields(users...)
 }
 
 func (r *SQLiteRepository) GetUserById(user_id int64) (*User, error) {
 	row := r.db.QueryRow(QueryReadUserById, user_id)
 
-	var user User
-	if err := row.Scan(&user.UserID, &user.Name, &user.Last, &user.Email, &user.Password, &user.PFP, &user.CreatedAt, &user.Role); err != nil {
+	user, err := scanUser(row)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotExists
 		}
 		return nil, err
 	}
 	user.Password = ""
-	return &user, nil
+	return user, r.loadFields(user)
 }
 
-func (r *SQLiteRepository) UpdateUser(id int64, updated User) (*User, error) {
+// UpdateUser updates the profile, role, employee code unless keepCode is set
+// and, when not empty, the password of the user. A role with RoleID zero keeps
+// the current one. The last active admin cannot be moved to another role.
+func (r *SQLiteRepository) UpdateUser(id int64, updated User, keepCode bool) (*User, error) {
 	if id == 0 {
-		return nil, errors.New("invalid updated ID")
+		return nil, invalid("invalid_id")
+	}
+	current, err := r.GetUserById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	role := current.Role
+	if updated.Role.RoleID != 0 && updated.Role.RoleID != role.RoleID {
+		if err := r.db.QueryRow(QueryReadRoleById, updated.Role.RoleID).Scan(&role.RoleID, &role.Name, &role.CreatedAt); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, invalid("role_not_found", updated.Role.RoleID)
+			}
+			return nil, err
+		}
+	}
+
+	password := ""
+	if updated.Password != "" {
+		if len(updated.Password) < 8 {
+			return nil, invalid("password_too_short")
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(updated.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		password = string(hashed)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if current.Role.Name == AdminRole && role.Name != AdminRole && current.Active {
+		var admins int
+		if err := tx.QueryRow(QueryCountActiveAdmins).Scan(&admins); err != nil {
+			return nil, err
+		}
+		if admins <= 1 {
+			return nil, ErrLastAdmin
+		}
 	}
-	res, err := r.db.Exec(QueryUpdateUser, updated.Name, updated.Last, updated.Email, id)
+
+	res, err := tx.Exec(QueryUpdateUser, updated.Name, updated.Last, updated.Email, keepCode, updated.EmployeeCode, role.RoleID, id)
 	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) {
+			if errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+				return nil, ErrDuplicate
+			}
+		}
 		return nil, err
 	}
 
@@ -118,11 +220,99 @@ func (r *SQLiteRepository) UpdateUser(id int64, updated User) (*User, error) {
 		return nil, ErrUpdateFailed
 	}
 
-	return &updated, nil
+	if password != "" {
+		if _, err := tx.Exec(QuerySetPassword, password, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetUserById(id)
+}
+
+// DeactivateUser soft deletes the user: it can no longer log in and is hidden
+// from the user list, but its history is kept. Its pending invitations, which
+// would let it set a password again, are revoked. The last active admin
+// cannot be deactivated.
+func (r *SQLiteRepository) DeactivateUser(id int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		active bool
+		role   string
+	)
+	if err := tx.QueryRow(QueryReadUserRole, id).Scan(&active, &role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotExists
+		}
+		return err
+	}
+	if role == AdminRole && active {
+		var admins int
+		if err := tx.QueryRow(QueryCountActiveAdmins).Scan(&admins); err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return ErrLastAdmin
+		}
+	}
+
+	res, err := tx.Exec(QueryDeactivateUser, time.Now().Format(time.RFC3339), id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUpdateFailed
+	}
+	if _, err := tx.Exec(QueryRevokeInvitations, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteRepository) ReactivateUser(id int64) error {
+	return r.setUserActive(QueryReactivateUser, id)
+}
+
+// IsActive tells whether the user exists and is active, without loading the
+// rest of its profile.
+func (r *SQLiteRepository) IsActive(id int64) (bool, error) {
+	var active bool
+	err := r.db.QueryRow(QueryReadUserActive, id).Scan(&active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return active, err
+}
+
+// GetUserRole returns the name of the role of the user and whether it is
+// active, without loading the rest of its profile, to authorize requests.
+func (r *SQLiteRepository) GetUserRole(id int64) (string, bool, error) {
+	var (
+		active bool
+		role   string
+	)
+	if err := r.db.QueryRow(QueryReadUserRole, id).Scan(&active, &role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, ErrNotExists
+		}
+		return "", false, err
+	}
+	return role, active, nil
 }
 
-func (r *SQLiteRepository) DeleteUser(id int64) error {
-	res, err := r.db.Exec(QueryDeleteUser, id)
+func (r *SQLiteRepository) setUserActive(query string, args ...any) error {
+	res, err := r.db.Exec(query, args...)
 	if err != nil {
 		return err
 	}
@@ -133,8 +323,239 @@ func (r *SQLiteRepository) DeleteUser(id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return ErrDeleteFailed
+		return ErrUpdateFailed
 	}
 
 	return err
 }
+
+// PurgeUser permanently deletes a deactivated user and everything recorded
+// about it. Both its deactivation and its last clocking must be older than
+// retention, so records needed for labor audits are never lost. Returns the
+// user as it was, so the caller can dispose of its profile image.
+func (r *SQLiteRepository) PurgeUser(id int64, retention time.Duration) (*User, error) {
+	user, err := r.GetUserById(id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Active {
+		return nil, invalid("purge_active")
+	}
+
+	limit := time.Now().Add(-retention).Format(time.RFC3339)
+	var last string
+	if err := r.db.QueryRow(QueryLastClocking, id).Scan(&last); err != nil {
+		return nil, err
+	}
+	if user.DeactivatedAt > limit || last > limit {
+		return nil, invalid("retention_pending", retentionEnd(max(user.DeactivatedAt, last), retention))
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{tableClockings, tableLedger, tableNotifications, tableEntitlements, tableInvitations, tableFieldValues, tableContracts} {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE user_id_fk = ?", table), id); err != nil {
+			return nil, err
+		}
+	}
+	// Mails are addressed by email rather than by id
+	if _, err := tx.Exec(QueryDeleteOutboxMail, user.Email); err != nil {
+		return nil, err
+	}
+	// Its reports are left without manager
+	if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET manager_id_fk = 0 WHERE manager_id_fk = ?", tableUsers), id); err != nil {
+		return nil, err
+	}
+	res, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE user_id = ?", tableUsers), id)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, ErrDeleteFailed
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func retentionEnd(date string, retention time.Duration) string {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return date
+	}
+	return t.Add(retention).Format(time.DateOnly)
+}
+
+var QueryCountUsers = fmt.Sprintf("SELECT COUNT(*) FROM %s u JOIN %s r ON u.role_id_fk = r.role_id", tableUsers, tableRoles)
+
+// userSortColumns maps the JSON names accepted by UserQuery.Sort to columns.
+var userSortColumns = map[string]string{
+	"_id":          "u.user_id",
+	"firstName":    "u.name",
+	"lastName":     "u.last",
+	"email":        "u.email",
+	"createdAt":    "u.createdAt",
+	"employeeCode": "u.code",
+	"role":         "r.name",
+	"active":       "u.active",
+}
+
+// UserQuery pages through users either by Page or, when sorting by _id, by
+// Cursor: the _id of the last user of the previous page. Status is active (the
+// default), inactive or all. Fields keeps the users whose custom fields hold
+// exactly the given values.
+type UserQuery struct {
+	Page   int
+	Limit  int
+	Cursor int64
+	Search string
+	RoleID int64
+	Status string
+	Fields map[string]string
+	Sort   string
+	Desc   bool
+}
+
+type UserPage struct {
+	Data       []User `json:"data"`
+	Total      int    `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	NextCursor int64  `json:"nextCursor,omitempty"`
+}
+
+func (r *SQLiteRepository) SearchUsers(query UserQuery) (*UserPage, error) {
+	if query.Sort == "" {
+		query.Sort = "_id"
+	}
+	column, ok := userSortColumns[query.Sort]
+	if !ok {
+		return nil, invalid("invalid_sort", query.Sort)
+	}
+	if query.Cursor != 0 && query.Sort != "_id" {
+		return nil, invalid("cursor_needs_id_sort")
+	}
+	if query.Limit <= 0 || query.Limit > 100 {
+		return nil, invalid("invalid_limit")
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+	// Every term of the search must appear in the name, last name or email
+	for _, term := range strings.Fields(query.Search) {
+		where = append(where, "(u.name LIKE ? OR u.last LIKE ? OR u.email LIKE ?)")
+		like := "%" + term + "%"
+		args = append(args, like, like, like)
+	}
+	if query.RoleID != 0 {
+		where = append(where, "r.role_id = ?")
+		args = append(args, query.RoleID)
+	}
+	switch query.Status {
+	case "", "active":
+		where = append(where, "u.active = 1")
+	case "inactive":
+		where = append(where, "u.active = 0")
+	case "all":
+	default:
+		return nil, invalid("invalid_status")
+	}
+	fieldWhere, fieldArgs := fieldFilter(query.Fields)
+	where = append(where, fieldWhere...)
+	args = append(args, fieldArgs...)
+	filter := ""
+	if len(where) > 0 {
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	page := UserPage{Data: []User{}, Limit: query.Limit}
+	if err := r.db.QueryRow(QueryCountUsers+filter, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	direction := "ASC"
+	if query.Desc {
+		direction = "DESC"
+	}
+	if query.Cursor != 0 {
+		operator := ">"
+		if query.Desc {
+			operator = "<"
+		}
+		if filter == "" {
+			filter = " WHERE "
+		} else {
+			filter += " AND "
+		}
+		filter += "u.user_id " + operator + " ?"
+		args = append(args, query.Cursor)
+	} else {
+		page.Page = query.Page
+	}
+	statement := fmt.Sprintf("%s%s ORDER BY %s %s, u.user_id %s LIMIT ? OFFSET ?", QueryReadUser, filter, column, direction, direction)
+	offset := 0
+	if query.Cursor == 0 {
+		offset = (query.Page - 1) * query.Limit
+	}
+	args = append(args, query.Limit, offset)
+
+	rows, err := r.db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		user.Password = ""
+		page.Data = append(page.Data, *user)
+	}
+	users := make([]*User, len(page.Data))
+	for i := range page.Data {
+		users[i] = &page.Data[i]
+	}
+	if err := r.loadFields(users...); err != nil {
+		return nil, err
+	}
+	if query.Sort == "_id" && len(page.Data) == query.Limit {
+		page.NextCursor = page.Data[len(page.Data)-1].UserID
+	}
+	return &page, nil
+}
+
+// scanUser reads a user joined with its role as selected by SELECT * on
+// users JOIN roles.
+func scanUser(row interface{ Scan(dest ...any) error }) (*User, error) {
+	var (
+		user User
+		fk   string
+	)
+	err := row.Scan(&user.UserID, &user.Name, &user.Last, &user.Email, &user.Password, &user.PFP, &user.CreatedAt, &user.EmployeeCode, &user.Active, &user.DeactivatedAt, &user.TeamID, &user.ManagerID, &user.Lang, &fk, &user.Role.RoleID, &user.Role.Name, &user.Role.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	// Users without an upload are told apart by their generated avatar
+	if user.PFP == DefaultPFP {
+		user.PFP = AvatarPFP(user.UserID)
+	}
+	return &user, nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import "testing"
+
+func TestPurgeUser(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	user, err := repo.CreateUser(UserToCreate{Name: "Gone", Email: "gone@example.com", PFP: "upload/gone.png", Role: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.QueueMail(user.Email, "Hello", "body"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeactivateUser(user.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := repo.PurgeUser(user.UserID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged.PFP != "upload/gone.png" {
+		t.Errorf("got profile image %q of the purged user, want upload/gone.png", purged.PFP)
+	}
+	var mails int
+	if err := repo.db.QueryRow("SELECT COUNT(*) FROM outbox WHERE recipient = ?", user.Email).Scan(&mails); err != nil {
+		t.Fatal(err)
+	}
+	if mails != 0 {
+		t.Errorf("got %d mails to the purged user, want 0", mails)
+	}
+}
+
+func TestUpdateUserEmployeeCode(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	user, err := repo.CreateUser(UserToCreate{Name: "Coded", Email: "coded@example.com", EmployeeCode: "E-1", Role: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept, err := repo.UpdateUser(user.UserID, User{Name: "Coded", Email: user.Email}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.EmployeeCode != "E-1" {
+		t.Errorf("got employee code %q with keepCode, want E-1", kept.EmployeeCode)
+	}
+	cleared, err := repo.UpdateUser(user.UserID, User{Name: "Coded", Email: user.Email}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleared.EmployeeCode != "" {
+		t.Errorf("got employee code %q, want it cleared", cleared.EmployeeCode)
+	}
+}
@@ -0,0 +1,161 @@
+// Package validate checks request bodies against the rules declared in the
+// validate tag of their fields, like `validate:"required,email,max=254"`.
+//
+// Rules are required, omitempty (skips the rest when the value is zero, or a
+// pointer to zero), email, date (YYYY-MM-DD), datetime (RFC 3339), oneof=a b c,
+// and min=N and max=N, which bound the length of strings and the value of
+// numbers.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Error is a rule broken by a field, named as in JSON. Rule is the broken
+// rule, with min and max on strings reported as min_length and max_length.
+type Error struct {
+	Field string
+	Rule  string
+	Param string
+}
+
+// Errors are every rule broken by a value.
+type Errors []Error
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, err := range e {
+		parts[i] = err.Field + ": " + err.Rule
+		if err.Param != "" {
+			parts[i] += "=" + err.Param
+		}
+	}
+	return "invalid fields: " + strings.Join(parts, ", ")
+}
+
+// Struct checks the fields of v, a struct or a pointer to one, and returns
+// Errors when some rule is broken. Values of other kinds are always valid.
+func Struct(v any) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	checkStruct(value, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkStruct(value reflect.Value, errs *Errors) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			checkStruct(value.Field(i), errs)
+			continue
+		}
+		rules := field.Tag.Get("validate")
+		if rules == "" || !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		if rule, param, ok := checkField(value.Field(i), rules); !ok {
+			*errs = append(*errs, Error{Field: name, Rule: rule, Param: param})
+		}
+	}
+}
+
+// checkField returns the first rule broken by value. Pointers are checked by
+// the value they point to, so a nil pointer and a pointer to a zero value are
+// both empty.
+func checkField(value reflect.Value, rules string) (string, string, bool) {
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+	empty := value.IsZero()
+	for _, rule := range strings.Split(rules, ",") {
+		switch rule {
+		case "required":
+			if empty {
+				return rule, "", false
+			}
+		case "omitempty":
+			if empty {
+				return "", "", true
+			}
+		}
+	}
+	if value.Kind() == reflect.Pointer {
+		return "", "", true
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "required" || name == "omitempty" {
+			continue
+		}
+		if broken, ok := check(value, name, param); !ok {
+			return broken, param, false
+		}
+	}
+	return "", "", true
+}
+
+// check applies a single rule, returning the name it is reported with.
+func check(value reflect.Value, rule string, param string) (string, bool) {
+	switch value.Kind() {
+	case reflect.String:
+		s := value.String()
+		switch rule {
+		case "email":
+			address, err := mail.ParseAddress(s)
+			return rule, err == nil && address.Address == s
+		case "date":
+			_, err := time.Parse(time.DateOnly, s)
+			return rule, err == nil
+		case "datetime":
+			_, err := time.Parse(time.RFC3339, s)
+			return rule, err == nil
+		case "oneof":
+			return rule, slices.Contains(strings.Fields(param), s)
+		case "min":
+			n, _ := strconv.Atoi(param)
+			return "min_length", utf8.RuneCountInString(s) >= n
+		case "max":
+			n, _ := strconv.Atoi(param)
+			return "max_length", utf8.RuneCountInString(s) <= n
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Float32, reflect.Float64:
+		number := value.Convert(reflect.TypeOf(float64(0))).Float()
+		bound, _ := strconv.ParseFloat(param, 64)
+		switch rule {
+		case "min":
+			return rule, number >= bound
+		case "max":
+			return rule, number <= bound
+		case "oneof":
+			return rule, slices.Contains(strings.Fields(param), fmt.Sprint(value.Interface()))
+		}
+	}
+	panic(fmt.Sprintf("validate: rule %q does not apply to %s", rule, value.Kind()))
+}
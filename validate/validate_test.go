@@ -0,0 +1,40 @@
+package validate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPointers(t *testing.T) {
+	type profile struct {
+		Lang *string `json:"language" validate:"omitempty,oneof=es en"`
+		Name *string `json:"name" validate:"required,max=5"`
+	}
+	text := func(s string) *string { return &s }
+
+	for _, test := range []struct {
+		value profile
+		want  []Error
+	}{
+		{profile{Name: text("Ana")}, nil},
+		{profile{Lang: text(""), Name: text("Ana")}, nil},
+		{profile{Lang: text("en"), Name: text("Ana")}, nil},
+		{profile{Lang: text("fr"), Name: text("Ana")}, []Error{{"language", "oneof", "es en"}}},
+		{profile{}, []Error{{"name", "required", ""}}},
+		{profile{Name: text("")}, []Error{{"name", "required", ""}}},
+		{profile{Name: text("Anabel")}, []Error{{"name", "max_length", "5"}}},
+	} {
+		err := Struct(test.value)
+		var errs Errors
+		errors.As(err, &errs)
+		if len(errs) != len(test.want) {
+			t.Errorf("%+v: got %v, want %v", test.value, errs, test.want)
+			continue
+		}
+		for i := range errs {
+			if errs[i] != test.want[i] {
+				t.Errorf("%+v: got %v, want %v", test.value, errs[i], test.want[i])
+			}
+		}
+	}
+}